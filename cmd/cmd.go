@@ -14,7 +14,6 @@ import (
 	"os/signal"
 	"regexp"
 	"syscall"
-	"time"
 )
 
 var ver = ""
@@ -105,7 +104,8 @@ func Spray() {
 		return
 	}
 
-	ctx, canceler := context.WithTimeout(context.Background(), time.Duration(runner.Deadline)*time.Second)
+	// deadline只停止分发新的任务, 不直接取消ctx, 保证已经发出的请求处理完毕并写入stat
+	ctx, canceler := context.WithCancel(context.Background())
 
 	err = runner.Prepare(ctx)
 	if err != nil {
@@ -5,7 +5,6 @@ import (
 	"github.com/antonmedv/expr"
 	"github.com/chainreactors/files"
 	"github.com/chainreactors/logs"
-	"github.com/chainreactors/parsers/iutils"
 	"github.com/chainreactors/spray/pkg"
 	"github.com/chainreactors/spray/pkg/ihttp"
 	"github.com/chainreactors/utils"
@@ -47,6 +46,7 @@ type InputOptions struct {
 	Offset       int      `long:"offset" description:"Int, wordlist offset"`
 	Limit        int      `long:"limit" description:"Int, wordlist limit, start with offset. e.g.: --offset 1000 --limit 100"`
 	Word         string   `short:"w" long:"word" description:"String, word generate dsl, e.g.: -w test{?ld#4}"`
+	Stream       bool     `long:"stream" description:"Bool, read dictionaries from disk line by line instead of loading into memory, for huge dictionaries"`
 	Rules        []string `short:"r" long:"rules" description:"Files, rule files, e.g.: -r rule1.txt -r rule2.txt"`
 	AppendRule   []string `long:"append-rule" description:"Files, when found valid path , use append rule generator new word with current path"`
 	FilterRule   string   `long:"filter-rule" description:"String, filter rule, e.g.: --rule-filter '>8 <4'"`
@@ -55,6 +55,7 @@ type InputOptions struct {
 type FunctionOptions struct {
 	Extensions        string            `short:"e" long:"extension" description:"String, add extensions (separated by commas), e.g.: -e jsp,jspx"`
 	ExcludeExtensions string            `long:"exclude-extension" description:"String, exclude extensions (separated by commas), e.g.: --exclude-extension jsp,jspx"`
+	AutoExtension     bool              `long:"auto-ext" description:"Bool, choose extensions for each target by fingerprint, server header and probes of random .php/.aspx/.jsp, e.g.: --auto-ext"`
 	RemoveExtensions  string            `long:"remove-extension" description:"String, remove extensions (separated by commas), e.g.: --remove-extension jsp,jspx"`
 	Uppercase         bool              `short:"U" long:"uppercase" description:"Bool, upper wordlist, e.g.: --uppercase"`
 	Lowercase         bool              `short:"L" long:"lowercase" description:"Bool, lower wordlist, e.g.: --lowercase"`
@@ -64,16 +65,29 @@ type FunctionOptions struct {
 }
 
 type OutputOptions struct {
-	Match       string `long:"match" description:"String, custom match function, e.g.: --match current.Status != 200" json:"match,omitempty"`
-	Filter      string `long:"filter" description:"String, custom filter function, e.g.: --filter current.Body contains 'hello'" json:"filter,omitempty"`
-	OutputFile  string `short:"f" long:"file" description:"String, output filename" json:"output_file,omitempty"`
-	Format      string `short:"F" long:"format" description:"String, output format, e.g.: --format 1.json"`
-	FuzzyFile   string `long:"fuzzy-file" description:"String, fuzzy output filename" json:"fuzzy_file,omitempty"`
-	DumpFile    string `long:"dump-file" description:"String, dump all request, and write to filename"`
-	Dump        bool   `long:"dump" description:"Bool, dump all request"`
-	AutoFile    bool   `long:"auto-file" description:"Bool, auto generator output and fuzzy filename" `
-	Fuzzy       bool   `long:"fuzzy" description:"String, open fuzzy output" json:"fuzzy,omitempty"`
-	OutputProbe string `short:"o" long:"probe" description:"String, output format" json:"output_probe,omitempty"`
+	Match        string `long:"match" description:"String, custom match function, e.g.: --match current.Status != 200" json:"match,omitempty"`
+	Filter       string `long:"filter" description:"String, custom filter function, e.g.: --filter current.Body contains 'hello'" json:"filter,omitempty"`
+	MatchStatus  string `long:"mc" description:"String, match status, e.g.: --mc 200,300-399"`
+	MatchSize    string `long:"ms" description:"String, match body length, e.g.: --ms 1234,2000-3000"`
+	MatchWords   string `long:"mw" description:"String, match body words count, e.g.: --mw 12"`
+	MatchLines   string `long:"ml" description:"String, match body lines count, e.g.: --ml 5-10"`
+	MatchRegexp  string `long:"mr" description:"String, match response with regexp, e.g.: --mr 'admin.*panel'"`
+	MatchTime    string `long:"mt" description:"String, match response time (ms), e.g.: --mt '>100'"`
+	FilterStatus string `long:"fc" description:"String, filter status, e.g.: --fc 404,500-599"`
+	FilterSize   string `long:"fs" description:"String, filter body length, e.g.: --fs 1234,2000-3000"`
+	FilterWords  string `long:"fw" description:"String, filter body words count, e.g.: --fw 12"`
+	FilterLines  string `long:"fl" description:"String, filter body lines count, e.g.: --fl 5-10"`
+	FilterRegexp string `long:"fr" description:"String, filter response with regexp, e.g.: --fr 'not found'"`
+	FilterTime   string `long:"ft" description:"String, filter response time (ms), e.g.: --ft '<100'"`
+	OutputFile   string `short:"f" long:"file" description:"String, output filename" json:"output_file,omitempty"`
+	Format       string `short:"F" long:"format" description:"String, output format, e.g.: --format 1.json"`
+	FuzzyFile    string `long:"fuzzy-file" description:"String, fuzzy output filename" json:"fuzzy_file,omitempty"`
+	TreeFile     string `long:"tree-file" description:"String, write recursive directory tree to filename"`
+	DumpFile     string `long:"dump-file" description:"String, dump all request, and write to filename"`
+	Dump         bool   `long:"dump" description:"Bool, dump all request"`
+	AutoFile     bool   `long:"auto-file" description:"Bool, auto generator output and fuzzy filename" `
+	Fuzzy        bool   `long:"fuzzy" description:"String, open fuzzy output" json:"fuzzy,omitempty"`
+	OutputProbe  string `short:"o" long:"probe" description:"String, output format" json:"output_probe,omitempty"`
 }
 
 type RequestOptions struct {
@@ -86,16 +100,28 @@ type RequestOptions struct {
 }
 
 type PluginOptions struct {
-	Advance    bool     `short:"a" long:"advance" description:"Bool, enable crawl and active"`
-	Extracts   []string `long:"extract" description:"Strings, extract response, e.g.: --extract js --extract ip --extract version:(.*?)"`
-	Recon      bool     `long:"recon" description:"Bool, enable recon"`
-	Active     bool     `long:"active" description:"Bool, enable active finger detect"`
-	Bak        bool     `long:"bak" description:"Bool, enable bak found"`
-	FileBak    bool     `long:"file-bak" description:"Bool, enable valid result bak found, equal --append-rule rule/filebak.txt"`
-	Common     bool     `long:"common" description:"Bool, enable common file found"`
-	Crawl      bool     `long:"crawl" description:"Bool, enable crawl"`
-	CrawlDepth int      `long:"crawl-depth" default:"3" description:"Int, crawl depth"`
-	CrawlScope string   `long:"crawl-scope" description:"Int, crawl scope (todo)"`
+	Advance      bool     `short:"a" long:"advance" description:"Bool, enable crawl and active"`
+	Extracts     []string `long:"extract" description:"Strings, extract response, e.g.: --extract js --extract ip --extract version:(.*?)"`
+	Recon        bool     `long:"recon" description:"Bool, enable recon"`
+	Active       bool     `long:"active" description:"Bool, enable active finger detect"`
+	Bak          bool     `long:"bak" description:"Bool, enable bak found"`
+	FileBak      bool     `long:"file-bak" description:"Bool, enable valid result bak found, equal --append-rule rule/filebak.txt"`
+	Common       bool     `long:"common" description:"Bool, enable common file found"`
+	Leak         bool     `long:"leak" description:"Bool, parse leaked .git/index, packed-refs, .svn/wc.db, .svn/entries, .hg, .DS_Store and WEB-INF/web.xml, and spray every file they reveal"`
+	Seed         bool     `long:"seed" description:"Bool, seed tasks from robots.txt (including disallow), sitemaps (index and gzip) and /.well-known/ URIs"`
+	JsAnalyze    bool     `long:"js" description:"Bool, fetch scripts and their source maps, enumerate webpack chunks, and spray api paths found in js"`
+	API          bool     `long:"api" description:"Bool, parse swagger/openapi specs and graphql introspection, request every declared endpoint with its method and placeholder params, and report 200/401/403/405 classes, only GET/HEAD/OPTIONS and graphql queries are sent by default"`
+	APIUnsafe    bool     `long:"api-unsafe" description:"Bool, also send state-changing endpoints (POST/PUT/PATCH/DELETE) and graphql mutations declared in api specs, may modify or delete data"`
+	Pack         bool     `long:"pack" description:"Bool, enable framework path packs triggered by fingerprints, e.g. spring-boot actuator, tomcat manager"`
+	Crawl        bool     `long:"crawl" description:"Bool, enable crawl"`
+	Harvest      bool     `long:"harvest" description:"Bool, harvest words from valid responses (path segments, params, js identifiers, title and body words) and spray them in the current directory"`
+	HarvestMin   int      `long:"harvest-min" default:"3" description:"Int, min length of harvested word"`
+	HarvestMax   int      `long:"harvest-max" default:"24" description:"Int, max length of harvested word"`
+	HarvestLimit int      `long:"harvest-limit" default:"1000" description:"Int, max number of harvested words across all targets, 0 for unlimited"`
+	HarvestPage  int      `long:"harvest-page" default:"50" description:"Int, max number of new words harvested from one response, 0 for unlimited"`
+	HarvestFile  string   `long:"harvest-file" description:"String, write harvested words to filename, e.g.: --harvest-file words.txt"`
+	CrawlDepth   int      `long:"crawl-depth" default:"3" description:"Int, crawl depth"`
+	CrawlScope   string   `long:"crawl-scope" description:"Int, crawl scope (todo)"`
 }
 
 type ModeOptions struct {
@@ -106,30 +132,47 @@ type ModeOptions struct {
 	Scope           []string `long:"scope" description:"String, custom scope, e.g.: --scope *.example.com"`
 	Recursive       string   `long:"recursive" default:"current.IsDir()" description:"String,custom recursive rule, e.g.: --recursive current.IsDir()"`
 	Depth           int      `long:"depth" default:"0" description:"Int, recursive depth"`
+	RecursiveLimit  int      `long:"recursive-limit" default:"500" description:"Int, max number of recursive tasks across all targets, 0 for unlimited"`
+	RecursivePolicy string   `long:"recursive-policy" description:"File, recursion policies (yaml), choose dicts, rules, extensions and max depth by directory path or depth, e.g.: --recursive-policy policy.yaml"`
 	CheckPeriod     int      `long:"check-period" default:"200" description:"Int, check period when request"`
 	ErrPeriod       int      `long:"error-period" default:"10" description:"Int, check period when error"`
 	BreakThreshold  int      `long:"error-threshold" default:"20" description:"Int, break when the error exceeds the threshold "`
+	CoolDown        int      `long:"cool-down" default:"0" description:"Int, pause and re-check with exponential cool-down (seconds) when the error exceeds the threshold instead of break, e.g.: --cool-down 30"`
+	MaxBanTime      int      `long:"max-ban-time" default:"600" description:"Int, give up when total cool-down time exceeds (seconds)"`
+	IgnoreWaf       bool     `long:"ignore-waf" description:"Bool, disable waf detection, including block page, waf probe and waf fingerprint"`
+	WafProbe        bool     `long:"waf-probe" description:"Bool, send a request carrying sqli/lfi/xss payloads at init to learn the waf block page, noisy"`
 	BlackStatus     string   `long:"black-status" default:"400,410" description:"Strings (comma split),custom black status, "`
 	WhiteStatus     string   `long:"white-status" default:"200" description:"Strings (comma split), custom white status"`
 	FuzzyStatus     string   `long:"fuzzy-status" default:"404,403,500,501,502,503" description:"Strings (comma split), custom fuzzy status"`
 	UniqueStatus    string   `long:"unique-status" default:"403" description:"Strings (comma split), custom unique status"`
 	Unique          bool     `long:"unique" description:"Bool, unique response"`
 	RetryCount      int      `long:"retry" default:"1" description:"Int, retry count"`
+	RetryPolicy     string   `long:"retry-policy" default:"body-too-large:0" description:"String, retry count by error class (dns/refused/reset/tls/timeout/proxy/other), others use --retry, e.g.: --retry-policy timeout:3,dns:0"`
+	RetryBackoff    int      `long:"retry-backoff" default:"200" description:"Int, base backoff (ms) of retry, doubled on each retry with jitter"`
 	SimhashDistance int      `long:"distance" default:"5"`
+	DedupThreshold  int      `long:"dedup-threshold" default:"100000" description:"Int, switch url deduplication from exact set to bloom filter when exceeds the threshold"`
+	DedupFPRate     float64  `long:"dedup-fp" default:"0.0001" description:"Float, false positive rate of bloom filter deduplication, e.g.: --dedup-fp 0.001"`
+	Mirror          string   `long:"mirror" choice:"skip" choice:"sample" choice:"reuse" description:"String, detect mirrored targets by index and random baseline, then skip/sample/reuse, e.g.: --mirror skip"`
+	MirrorSample    int      `long:"mirror-sample" default:"500" description:"Int, number of words sprayed against mirrored target when --mirror sample"`
+	ClusterRatio    float64  `long:"cluster-ratio" default:"0" description:"Float, mark a cluster of similar responses as soft-404 when it exceeds the ratio of valid responses, e.g. 0.3, results are held until their cluster reaches cluster-min, 0 to disable"`
+	ClusterMin      int      `long:"cluster-min" default:"20" description:"Int, minimum size of a cluster before it can be marked as soft-404"`
 }
 
 type MiscOptions struct {
-	Deadline int    `long:"deadline" default:"999999" description:"Int, deadline (seconds)"` // todo 总的超时时间,适配云函数的deadline
-	Timeout  int    `long:"timeout" default:"5" description:"Int, timeout with request (seconds)"`
-	PoolSize int    `short:"P" long:"pool" default:"5" description:"Int, Pool size"`
-	Threads  int    `short:"t" long:"thread" default:"20" description:"Int, number of threads per pool"`
-	Debug    bool   `long:"debug" description:"Bool, output debug info"`
-	Version  bool   `short:"v" long:"version" description:"Bool, show version"`
-	Quiet    bool   `short:"q" long:"quiet" description:"Bool, Quiet"`
-	NoColor  bool   `long:"no-color" description:"Bool, no color"`
-	NoBar    bool   `long:"no-bar" description:"Bool, No progress bar"`
-	Mod      string `short:"m" long:"mod" default:"path" choice:"path" choice:"host" description:"String, path/host spray"`
-	Client   string `short:"C" long:"client" default:"auto" choice:"fast" choice:"standard" choice:"auto" description:"String, Client type"`
+	Deadline       int    `long:"deadline" default:"999999" description:"Int, deadline (seconds), stop dispatching before deadline and save resumable stat"`
+	DeadlineGrace  int    `long:"deadline-grace" default:"10" description:"Int, stop dispatching new words the given seconds before deadline, leave time to drain in-flight requests"`
+	TargetTime     int    `long:"target-time" default:"0" description:"Int, time budget (seconds) per target, 0 for unlimited"`
+	TargetRequests int    `long:"target-requests" default:"0" description:"Int, request budget per target, 0 for unlimited"`
+	Timeout        int    `long:"timeout" default:"5" description:"Int, timeout with request (seconds)"`
+	PoolSize       int    `short:"P" long:"pool" default:"5" description:"Int, Pool size"`
+	Threads        int    `short:"t" long:"thread" default:"20" description:"Int, number of threads per pool"`
+	Debug          bool   `long:"debug" description:"Bool, output debug info"`
+	Version        bool   `short:"v" long:"version" description:"Bool, show version"`
+	Quiet          bool   `short:"q" long:"quiet" description:"Bool, Quiet"`
+	NoColor        bool   `long:"no-color" description:"Bool, no color"`
+	NoBar          bool   `long:"no-bar" description:"Bool, No progress bar"`
+	Mod            string `short:"m" long:"mod" default:"path" choice:"path" choice:"host" description:"String, path/host spray"`
+	Client         string `short:"C" long:"client" default:"auto" choice:"fast" choice:"standard" choice:"auto" description:"String, Client type"`
 }
 
 func (opt *Option) PrepareRunner() (*Runner, error) {
@@ -138,6 +181,10 @@ func (opt *Option) PrepareRunner() (*Runner, error) {
 		return nil, fmt.Errorf("validate failed")
 	}
 	var err error
+	err = opt.prepareShortcuts()
+	if err != nil {
+		return nil, err
+	}
 	r := &Runner{
 		Progress:        uiprogress.New(),
 		Threads:         opt.Threads,
@@ -146,6 +193,9 @@ func (opt *Option) PrepareRunner() (*Runner, error) {
 		Timeout:         opt.Timeout,
 		RateLimit:       opt.RateLimit,
 		Deadline:        opt.Deadline,
+		DeadlineGrace:   opt.DeadlineGrace,
+		TargetTime:      opt.TargetTime,
+		TargetRequests:  opt.TargetRequests,
 		Headers:         make(map[string]string),
 		Offset:          opt.Offset,
 		Total:           opt.Limit,
@@ -163,8 +213,35 @@ func (opt *Option) PrepareRunner() (*Runner, error) {
 		Active:          opt.Active,
 		Bak:             opt.Bak,
 		Common:          opt.Common,
+		Pack:            opt.Pack,
+		Leak:            opt.Leak,
+		Seed:            opt.Seed,
+		JsAnalyze:       opt.JsAnalyze,
+		API:             opt.API,
+		APIUnsafe:       opt.APIUnsafe,
 		RetryCount:      opt.RetryCount,
+		RetryBackoff:    opt.RetryBackoff,
 		RandomUserAgent: opt.RandomUserAgent,
+		Dedup:           pkg.NewDeduplicator(opt.DedupThreshold, opt.DedupFPRate),
+		DirTree:         pkg.NewDirTree(opt.RecursiveLimit),
+		AutoExt:         opt.AutoExtension,
+		Mirror:          opt.Mirror,
+		MirrorSample:    opt.MirrorSample,
+		ClusterRatio:    opt.ClusterRatio,
+		ClusterMin:      opt.ClusterMin,
+		CoolDown:        opt.CoolDown,
+		MaxBanTime:      opt.MaxBanTime,
+		IgnoreWaf:       opt.IgnoreWaf,
+		WafProbe:        opt.WafProbe,
+	}
+
+	r.RetryPolicy, err = parseRetryPolicy(opt.RetryPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Mirror != "" {
+		r.Mirrors = pkg.NewMirrors()
 	}
 
 	// log and bar
@@ -211,6 +288,11 @@ func (opt *Option) PrepareRunner() (*Runner, error) {
 		r.Active = true
 		r.Bak = true
 		r.Common = true
+		r.Pack = true
+		r.Leak = true
+		r.Seed = true
+		r.JsAnalyze = true
+		r.API = true
 		pkg.Extractors["recon"] = pkg.ExtractRegexps["pentest"]
 		opt.AppendRule = append(opt.AppendRule, "filebak")
 	} else if opt.FileBak {
@@ -230,6 +312,29 @@ func (opt *Option) PrepareRunner() (*Runner, error) {
 	if r.Common {
 		s.WriteString("common file enable; ")
 	}
+	if r.Pack {
+		s.WriteString("framework pack enable; ")
+	}
+	if r.Leak {
+		s.WriteString("vcs leak enable; ")
+	}
+	if r.Seed {
+		s.WriteString("robots and sitemap seed enable; ")
+	}
+	if r.JsAnalyze {
+		s.WriteString("js analyze enable; ")
+	}
+	if r.API {
+		s.WriteString("api spec enable; ")
+		if opt.APIUnsafe {
+			s.WriteString("api unsafe methods enable; ")
+		}
+	}
+	if opt.Harvest || opt.HarvestFile != "" {
+		r.Harvester = pkg.NewHarvester(opt.HarvestMin, opt.HarvestMax, opt.HarvestLimit)
+		r.Harvester.PerPage = opt.HarvestPage
+		s.WriteString("word harvest enable; ")
+	}
 	if opt.Recon {
 		s.WriteString("recon enable; ")
 	}
@@ -259,8 +364,16 @@ func (opt *Option) PrepareRunner() (*Runner, error) {
 	}
 
 	// prepare word
+	customWord := opt.Word != "" || len(opt.Dictionaries) > 0
 	dicts := make([][]string, len(opt.Dictionaries))
 	for i, f := range opt.Dictionaries {
+		if opt.Stream {
+			// 流式模式下只统计行数, 在请求时才逐行读取
+			if _, err := countLines(f); err != nil {
+				return nil, err
+			}
+			continue
+		}
 		dicts[i], err = loadFileToSlice(f)
 		if opt.ResumeFrom != "" {
 			dictCache[f] = dicts[i]
@@ -292,8 +405,9 @@ func (opt *Option) PrepareRunner() (*Runner, error) {
 		opt.Word = "{@prefix}" + opt.Word
 	}
 
+	var exts []string
 	if opt.Extensions != "" {
-		exts := strings.Split(opt.Extensions, ",")
+		exts = strings.Split(opt.Extensions, ",")
 		for i, e := range exts {
 			if !strings.HasPrefix(e, ".") {
 				exts[i] = "." + e
@@ -303,9 +417,21 @@ func (opt *Option) PrepareRunner() (*Runner, error) {
 		opt.Word += "{@ext}"
 	}
 
-	r.Wordlist, err = mask.Run(opt.Word, dicts, nil)
-	if err != nil {
-		return nil, fmt.Errorf("%s %w", opt.Word, err)
+	if opt.Stream {
+		r.Stream, err = newWordStream(opt.Word, opt.Dictionaries, nil)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		r.Wordlist, err = mask.Run(opt.Word, dicts, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", opt.Word, err)
+		}
+	}
+	if opt.ResumeFrom != "" && !customWord {
+		// 断点续传且命令行未指定字典, 使用stat中保存的字典
+		r.Wordlist = nil
+		r.Stream = nil
 	}
 	if len(r.Wordlist) > 0 {
 		logs.Log.Importantf("Parsed %d words by %s", len(r.Wordlist), opt.Word)
@@ -324,20 +450,50 @@ func (opt *Option) PrepareRunner() (*Runner, error) {
 		r.Rules = new(rule.Program)
 	}
 
+	wordCount := len(r.Wordlist)
+	if r.Stream != nil {
+		wordCount = r.Stream.Count
+		logs.Log.Importantf("Streaming %d words by %s", wordCount, opt.Word)
+	}
 	if len(r.Rules.Expressions) > 0 {
-		r.Total = len(r.Wordlist) * len(r.Rules.Expressions)
+		r.Total = wordCount * len(r.Rules.Expressions)
 	} else {
-		r.Total = len(r.Wordlist)
+		r.Total = wordCount
 	}
 
 	pkg.DefaultStatistor = pkg.Statistor{
 		Word:         opt.Word,
-		WordCount:    len(r.Wordlist),
+		WordCount:    wordCount,
 		Dictionaries: opt.Dictionaries,
 		Offset:       opt.Offset,
 		RuleFiles:    opt.Rules,
 		RuleFilter:   opt.FilterRule,
 		Total:        r.Total,
+		Generator: &pkg.Generator{
+			Extensions:  exts,
+			Prefixes:    opt.Prefixes,
+			Suffixes:    opt.Suffixes,
+			Replaces:    opt.Replaces,
+			Uppercase:   opt.Uppercase,
+			Lowercase:   opt.Lowercase,
+			AppendRules: opt.AppendRule,
+			MatchExpr:   opt.Match,
+			FilterExpr:  opt.Filter,
+			MaxDepth:    opt.Depth,
+			Stream:      opt.Stream,
+		},
+	}
+	if opt.Stream {
+		pkg.DefaultStatistor.Generator.LineCounts = lineCounts(opt.Dictionaries)
+	}
+	if opt.ExcludeExtensions != "" {
+		pkg.DefaultStatistor.Generator.ExcludeExtensions = strings.Split(opt.ExcludeExtensions, ",")
+	}
+	if opt.RemoveExtensions != "" {
+		pkg.DefaultStatistor.Generator.RemoveExtensions = strings.Split(opt.RemoveExtensions, ",")
+	}
+	if opt.Depth != 0 || opt.Recursive != "current.IsDir()" {
+		pkg.DefaultStatistor.Generator.RecuExpr = opt.Recursive
 	}
 
 	if opt.AppendRule != nil {
@@ -353,16 +509,18 @@ func (opt *Option) PrepareRunner() (*Runner, error) {
 	// prepare task
 	tasks := make(chan *Task, opt.PoolSize)
 	var taskfrom string
+	var stats pkg.Statistors
 	if opt.ResumeFrom != "" {
-		stats, err := pkg.ReadStatistors(opt.ResumeFrom)
+		stats, err = pkg.ReadStatistors(opt.ResumeFrom)
 		if err != nil {
 			logs.Log.Error(err.Error())
 		}
-		r.Count = len(stats)
+		resumed := resumeTasks(stats)
+		r.Count = len(resumed)
 		taskfrom = "resume " + opt.ResumeFrom
 		go func() {
-			for _, stat := range stats {
-				tasks <- &Task{baseUrl: stat.BaseUrl, origin: NewOrigin(stat)}
+			for _, t := range resumed {
+				tasks <- t
 			}
 			close(tasks)
 		}()
@@ -466,42 +624,13 @@ func (opt *Option) PrepareRunner() (*Runner, error) {
 	r.Tasks = tasks
 	logs.Log.Importantf("Loaded %d urls from %s", len(tasks), taskfrom)
 
-	if opt.Uppercase {
-		r.Fns = append(r.Fns, strings.ToUpper)
-	}
-	if opt.Lowercase {
-		r.Fns = append(r.Fns, strings.ToLower)
-	}
-
-	if opt.RemoveExtensions != "" {
-		rexts := strings.Split(opt.ExcludeExtensions, ",")
-		r.Fns = append(r.Fns, func(s string) string {
-			if ext := parseExtension(s); iutils.StringsContains(rexts, ext) {
-				return strings.TrimSuffix(s, "."+ext)
-			}
-			return s
-		})
-	}
-
-	if opt.ExcludeExtensions != "" {
-		exexts := strings.Split(opt.ExcludeExtensions, ",")
-		r.Fns = append(r.Fns, func(s string) string {
-			if ext := parseExtension(s); iutils.StringsContains(exexts, ext) {
-				return ""
-			}
-			return s
-		})
-	}
-
-	if len(opt.Replaces) > 0 {
-		r.Fns = append(r.Fns, func(s string) string {
-			for k, v := range opt.Replaces {
-				s = strings.Replace(s, k, v, -1)
-			}
-			return s
-		})
+	r.Fns = buildFns(pkg.DefaultStatistor.Generator)
+	if r.Stream != nil {
+		r.Stream.Rules = r.Rules.Expressions
+		r.Stream.Fns = r.Fns
 	}
 	logs.Log.Importantf("Loaded %d dictionaries and %d decorators", len(opt.Dictionaries), len(r.Fns))
+	r.Shapes = sampleShapes(r.Wordlist, r.Fns)
 
 	if opt.Match != "" {
 		exp, err := expr.Compile(opt.Match)
@@ -541,6 +670,21 @@ func (opt *Option) PrepareRunner() (*Runner, error) {
 		r.RecursiveExpr = exp
 	}
 
+	if opt.RecursivePolicy != "" {
+		r.Policies, err = LoadPolicies(opt.RecursivePolicy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(stats) > 0 {
+		// 断点续传时, 命令行中未指定的配置从stat中恢复
+		err = r.restoreFromStat(stats[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// prepare header
 	for _, h := range opt.Headers {
 		i := strings.Index(h, ":")
@@ -587,6 +731,20 @@ func (opt *Option) PrepareRunner() (*Runner, error) {
 		}
 	}
 
+	if opt.HarvestFile != "" {
+		r.HarvestFile, err = files.NewFile(opt.HarvestFile, false, false, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opt.TreeFile != "" {
+		r.TreeFile, err = files.NewFile(opt.TreeFile, false, false, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if opt.DumpFile != "" {
 		r.DumpFile, err = files.NewFile(opt.DumpFile, false, false, true)
 		if err != nil {
@@ -625,12 +783,6 @@ func (opt *Option) Validate() bool {
 		logs.Log.Error("--offset and --limit cannot be used with --depth at the same time")
 		return false
 	}
-
-	if opt.Depth > 0 && opt.ResumeFrom != "" {
-		// 递归与断点续传会造成混淆, 断点续传的word与rule不是通过命令行获取的
-		logs.Log.Error("--resume and --depth cannot be used at the same time")
-		return false
-	}
 	return true
 }
 
@@ -663,3 +815,55 @@ func (opt *Option) GenerateTasks(ch chan *Task, u string, ports []string) {
 		}
 	}
 }
+
+// prepareShortcuts 将--mc, --fs等ffuf风格的快捷参数编译为表达式, 与--match, --filter合并.
+// 多个match之间为或的关系, 与--match为且的关系; filter之间都为或的关系
+func (opt *Option) prepareShortcuts() error {
+	var matches, filters []string
+	for _, s := range []struct {
+		field, value string
+		exprs        *[]string
+	}{
+		{"current.Status", opt.MatchStatus, &matches},
+		{"current.BodyLength", opt.MatchSize, &matches},
+		{"current.Words", opt.MatchWords, &matches},
+		{"current.Lines", opt.MatchLines, &matches},
+		{"current.Spended", opt.MatchTime, &matches},
+		{"current.Status", opt.FilterStatus, &filters},
+		{"current.BodyLength", opt.FilterSize, &filters},
+		{"current.Words", opt.FilterWords, &filters},
+		{"current.Lines", opt.FilterLines, &filters},
+		{"current.Spended", opt.FilterTime, &filters},
+	} {
+		if s.value == "" {
+			continue
+		}
+		exp, err := shortcutExpr(s.field, s.value)
+		if err != nil {
+			return err
+		}
+		*s.exprs = append(*s.exprs, exp)
+	}
+	if opt.MatchRegexp != "" {
+		matches = append(matches, "current.MatchRegexp("+strconv.Quote(opt.MatchRegexp)+")")
+	}
+	if opt.FilterRegexp != "" {
+		filters = append(filters, "current.MatchRegexp("+strconv.Quote(opt.FilterRegexp)+")")
+	}
+
+	if len(matches) > 0 {
+		exp := strings.Join(matches, " || ")
+		if opt.Match != "" {
+			opt.Match = "(" + opt.Match + ") && (" + exp + ")"
+		} else {
+			opt.Match = exp
+		}
+	}
+	if len(filters) > 0 {
+		if opt.Filter != "" {
+			filters = append([]string{"(" + opt.Filter + ")"}, filters...)
+		}
+		opt.Filter = strings.Join(filters, " || ")
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/words"
+	"github.com/chainreactors/words/mask"
+	"github.com/chainreactors/words/rule"
+	"io/ioutil"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"strconv"
+	"strings"
+)
+
+// Policy 递归策略, 根据目录的路径或者递归深度选择不同的字典, 规则与后缀. 例如:
+//
+//   - name: api
+//     path: /api/*
+//     dicts: [api.txt]
+//     extensions: [json]
+//     max_depth: 3
+//   - name: deep
+//     depth: 2
+//     dicts: [small.txt]
+type Policy struct {
+	Name         string   `json:"name"`
+	Path         []string `json:"path,omitempty"`  // 目录路径的glob, 不包含末尾的"/"
+	Depth        int      `json:"depth,omitempty"` // 递归深度大于等于该值时生效
+	Word         string   `json:"word,omitempty"`  // 字典生成的dsl, 与-w相同
+	Dictionaries []string `json:"dicts,omitempty"`
+	Rules        []string `json:"rules,omitempty"`
+	Extensions   []string `json:"extensions,omitempty"`
+	MaxDepth     int      `json:"max_depth,omitempty"` // 使用该策略的任务的最大递归深度, 为0时使用--depth
+	rules        []rule.Expression
+}
+
+func LoadPolicies(filename string) ([]*Policy, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var policies []*Policy
+	err = yaml.Unmarshal(content, &policies)
+	if err != nil {
+		return nil, fmt.Errorf("parse recursive policy %s failed, %w", filename, err)
+	}
+
+	for i, p := range policies {
+		if p.Name == "" {
+			p.Name = "policy" + strconv.Itoa(i)
+		}
+		if p.Word == "" && len(p.Dictionaries) == 0 {
+			return nil, fmt.Errorf("recursive policy %s has neither word nor dicts", p.Name)
+		}
+		for _, glob := range p.Path {
+			if _, err := filepath.Match(glob, ""); err != nil {
+				return nil, fmt.Errorf("recursive policy %s, invalid path %s", p.Name, glob)
+			}
+		}
+		if p.Word == "" {
+			p.Word = "{?"
+			for j := range p.Dictionaries {
+				p.Word += strconv.Itoa(j)
+			}
+			p.Word += "}"
+		}
+		for j, e := range p.Extensions {
+			if !strings.HasPrefix(e, ".") {
+				p.Extensions[j] = "." + e
+			}
+		}
+		// 提前加载字典与规则, 任务创建时只读取缓存
+		if _, err := loadDictionaries(p.Dictionaries); err != nil {
+			return nil, err
+		}
+		if len(p.Rules) > 0 {
+			content, err := loadFileAndCombine(p.Rules)
+			if err != nil {
+				return nil, err
+			}
+			p.rules = rule.Compile(content, "").Expressions
+		}
+		logs.Log.Importantf("Loaded recursive policy %s, path: %s, depth: %d, word: %s", p.Name, strings.Join(p.Path, ","), p.Depth, p.Word)
+	}
+	return policies, nil
+}
+
+// Match 同时配置了path与depth时需要都满足
+func (p *Policy) Match(dir string, depth int) bool {
+	if len(p.Path) == 0 && p.Depth == 0 {
+		return false
+	}
+	if p.Depth != 0 && depth < p.Depth {
+		return false
+	}
+	if len(p.Path) > 0 && !MatchWithGlobs(strings.TrimSuffix(dir, "/"), p.Path) {
+		return false
+	}
+	return true
+}
+
+// word 包含后缀的完整dsl, 与-e的处理方式一致
+func (p *Policy) word() string {
+	if len(p.Extensions) > 0 {
+		return p.Word + "{@ext}"
+	}
+	return p.Word
+}
+
+// Worder 生成该策略的字典, 返回word的数量. 后缀通过keywords传入, 避免修改全局的mask.SpecialWords影响其他任务
+func (p *Policy) Worder(fns []func(string) string) (*words.Worder, int, error) {
+	dicts, err := loadDictionaries(p.Dictionaries)
+	if err != nil {
+		return nil, 0, err
+	}
+	keywords := make(map[string][]string, len(mask.SpecialWords)+1)
+	for k, v := range mask.SpecialWords {
+		keywords[k] = v
+	}
+	if len(p.Extensions) > 0 {
+		keywords["ext"] = p.Extensions
+	}
+	wl, err := mask.Run(p.word(), dicts, keywords)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s %w", p.word(), err)
+	}
+
+	worder := words.NewWorder(wl)
+	worder.Fns = fns
+	worder.Rules = p.rules
+	return worder, len(wl), nil
+}
+
+func MatchPolicy(policies []*Policy, dir string, depth int) *Policy {
+	for _, p := range policies {
+		if p.Match(dir, depth) {
+			return p
+		}
+	}
+	return nil
+}
@@ -28,6 +28,13 @@ var (
 	MaxRedirect     = 3
 	MaxCrawl        = 3
 	MaxRecursion    = 0
+	MaxShapes       = 16
+	MaxDirs         = 64
+	MaxSitemaps     = 16   // 每个目标最多请求的sitemap数量
+	MaxSeeds        = 5000 // 每个目标从robots.txt与sitemap中最多加入的路径数量
+	MaxJsPaths      = 2000 // 每个目标从js中最多加入的路径数量
+	MaxDrifts       = 3    // 连续多少次一致的check结果才认为random baseline发生了变化
+	MaxBackoff      = 30 * time.Second
 	enableAllFuzzy  = false
 	enableAllUnique = false
 	nilBaseline     = &pkg.Baseline{}
@@ -49,9 +56,12 @@ func NewPool(ctx context.Context, config *pkg.Config) (*Pool, error) {
 		cancel:      cancel,
 		client:      ihttp.NewClient(config.Thread, 2, config.ClientType),
 		baselines:   make(map[int]*pkg.Baseline),
-		urls:        make(map[string]struct{}),
-		scopeurls:   make(map[string]struct{}),
+		shapes:      make(map[string]*pkg.Baseline),
 		uniques:     make(map[uint16]struct{}),
+		packs:       make(map[string]bool),
+		archives:    make(map[string]bool),
+		leaks:       make(map[string]bool),
+		apis:        make(map[string]bool),
 		tempCh:      make(chan *pkg.Baseline, 100),
 		checkCh:     make(chan int, 100),
 		additionCh:  make(chan *Unit, 100),
@@ -61,6 +71,11 @@ func NewPool(ctx context.Context, config *pkg.Config) (*Pool, error) {
 		limiter:     rate.NewLimiter(rate.Limit(config.RateLimit), 1),
 		failedCount: 1,
 	}
+	if config.Mod == pkg.HostSpray {
+		// 所有域名都发往baseurl中的ip, Host与SNI使用待爆破的域名
+		pool.addr = hostPort(u)
+		pool.client.Bind(pool.addr)
+	}
 	rand.Seed(time.Now().UnixNano())
 	// 格式化dir, 保证至少有一个"/"
 	if strings.HasSuffix(config.BaseURL, "/") {
@@ -71,6 +86,10 @@ func NewPool(ctx context.Context, config *pkg.Config) (*Pool, error) {
 		pool.dir = Dir(pool.url.Path)
 	}
 
+	if config.ClusterRatio > 0 {
+		pool.clusters = pkg.NewClusters(config.ClusterRatio, config.ClusterMin)
+	}
+
 	pool.reqPool, _ = ants.NewPoolWithFunc(config.Thread, pool.Invoke)
 	pool.scopePool, _ = ants.NewPoolWithFunc(config.Thread, pool.NoScopeInvoke)
 
@@ -85,6 +104,7 @@ type Pool struct {
 	dir             string
 	isDir           bool
 	url             *url.URL
+	addr            string // host模式下实际连接的ip:port
 	Statistor       *pkg.Statistor
 	client          *ihttp.Client
 	reqPool         *ants.PoolWithFunc
@@ -97,6 +117,7 @@ type Pool struct {
 	additionCh      chan *Unit         // 插件添加的任务, 待处理管道
 	closeCh         chan struct{}
 	closed          bool
+	stopped         bool // 因deadline或预算停止分发
 	wordOffset      int
 	failedCount     int32
 	isFailed        bool
@@ -104,25 +125,40 @@ type Pool struct {
 	random          *pkg.Baseline
 	index           *pkg.Baseline
 	baselines       map[int]*pkg.Baseline
-	urls            map[string]struct{}
-	scopeurls       map[string]struct{}
+	shapes          map[string]*pkg.Baseline // 不同形态与目录的随机路径对应的baseline, 例如*.php, .*, /api/*
+	shapeCount      int
+	dirCount        int
+	clusters        *pkg.Clusters
+	wafBaseline     *pkg.Baseline // 携带攻击payload的请求触发的拦截页面
+	driftLocker     sync.Mutex
+	drifting        bool            // check发现random baseline可能发生变化, 确认之前暂存所有结果
+	held            []*pkg.Baseline // 确认期间暂存的结果
+	banCh           chan struct{}   // 冷却中不为nil, 冷却结束后关闭
+	banLocker       sync.Mutex
 	uniques         map[uint16]struct{}
+	packs           map[string]bool // 已经加入的路径包, key为目录+路径包名
+	archives        map[string]bool // 已经生成过打包文件的目录
+	leaks           map[string]bool // 已经请求过元数据文件的泄露目录
+	jsPaths         int             // 从js中加入的路径数量
+	apis            map[string]bool // 已经解析过的接口文档与graphql接口
 	analyzeDone     bool
 	worder          *words.Worder
+	stream          *wordStream
+	mirror          *pkg.Mirror // reuse模式下, 作为镜像组首个成员时收集结果
 	limiter         *rate.Limiter
 	locker          sync.Mutex
-	scopeLocker     sync.Mutex
 	waiter          sync.WaitGroup
 	initwg          sync.WaitGroup // 初始化用, 之后改成锁
 }
 
 func (pool *Pool) checkRedirect(redirectURL string) bool {
-	if pool.random.RedirectURL == "" {
+	random := pool.getRandom()
+	if random.RedirectURL == "" {
 		// 如果random的redirectURL为空, 此时该项
 		return true
 	}
 
-	if redirectURL == pool.random.RedirectURL {
+	if redirectURL == random.RedirectURL {
 		// 相同的RedirectURL将被认为是无效数据
 		return false
 	} else {
@@ -131,6 +167,53 @@ func (pool *Pool) checkRedirect(redirectURL string) bool {
 	}
 }
 
+// detectExtensions 根据index的指纹与响应头, 以及随机后缀路径与random baseline的差异, 选择该目标需要追加的后缀
+func (pool *Pool) detectExtensions() ([]string, []string) {
+	var exts []string
+	stacks, evidences := pkg.DetectTechStack(pool.index)
+	for _, stack := range stacks {
+		exts = append(exts, stack.Extensions...)
+	}
+
+	for _, ext := range pkg.ProbeExtensions {
+		shape := "*" + ext
+		bl := pool.probe(&Unit{path: pool.safePath(pkg.RandShapePath(shape)), source: InitRandomSource, shape: shape})
+		if bl == nil || bl.ErrString != "" {
+			continue
+		}
+		bl.Collect()
+		pool.locker.Lock()
+		if _, ok := pool.shapes[shape]; !ok && pool.shapeCount < MaxShapes {
+			// 探测结果同时作为该形态的baseline, 不需要再次校准
+			pool.shapes[shape] = bl
+			pool.shapeCount++
+		}
+		pool.locker.Unlock()
+		if bl.Status == pool.random.Status && similar(pool.random, bl) {
+			continue
+		}
+		exts = append(exts, ext)
+		evidences = append(evidences, "probe "+ext+": "+bl.Format([]string{"status", "length", "title"}))
+	}
+	return pkg.RemoveDuplication(exts), evidences
+}
+
+// maxRecursion 递归策略中指定的最大深度优先于--depth
+func (pool *Pool) maxRecursion() int {
+	if pool.MaxRecursion != 0 {
+		return pool.MaxRecursion
+	}
+	return MaxRecursion
+}
+
+// randomTarget 用于校准的随机目标, host模式下为随机域名
+func (pool *Pool) randomTarget() string {
+	if pool.Mod == pkg.HostSpray {
+		return pkg.RandHost()
+	}
+	return pool.safePath(pkg.RandPath())
+}
+
 func (pool *Pool) genReq(mod pkg.SprayMod, s string) (*ihttp.Request, error) {
 	if mod == pkg.HostSpray {
 		return ihttp.BuildHostRequest(pool.ClientType, pool.BaseURL, s)
@@ -144,7 +227,7 @@ func (pool *Pool) Init() error {
 	// 分成两步是为了避免闭包的线程安全问题
 	pool.initwg.Add(2)
 	pool.reqPool.Invoke(newUnit(pool.url.Path, InitIndexSource))
-	pool.reqPool.Invoke(newUnit(pool.safePath(pkg.RandPath()), InitRandomSource))
+	pool.reqPool.Invoke(newUnit(pool.randomTarget(), InitRandomSource))
 	pool.initwg.Wait()
 	if pool.index.ErrString != "" {
 		logs.Log.Error(pool.index.String())
@@ -160,6 +243,9 @@ func (pool *Pool) Init() error {
 		return fmt.Errorf(pool.index.ErrString)
 	}
 	logs.Log.Info("[baseline.random] " + pool.random.Format([]string{"status", "length", "spend", "title", "frame", "redirect"}))
+	if pool.WafProbe && !pool.IgnoreWaf {
+		pool.initWaf()
+	}
 
 	// 某些网站http会重定向到https, 如果发现随机目录出现这种情况, 则自定将baseurl升级为https
 	if pool.url.Scheme == "http" {
@@ -174,11 +260,31 @@ func (pool *Pool) Init() error {
 		}
 	}
 
+	if pool.Mod == pkg.PathSpray {
+		// 根据字典中出现的路径形态, 分别校准
+		pool.probeShapes(pool.Shapes...)
+	}
 	return nil
 }
 
 func (pool *Pool) Run(offset, limit int) {
-	pool.worder.RunWithRules()
+	if pool.stream != nil {
+		// 流式字典在任务真正开始时才打开文件. 使用独立的ctx, 因limit或预算正常结束时同样停止读取字典
+		ctx, cancel := context.WithCancel(pool.ctx)
+		worder := pool.stream.Worder(ctx)
+		defer func() {
+			cancel()
+			// 消费掉worder中剩余的word, 让worder的goroutine退出
+			go func() {
+				for range worder.C {
+				}
+			}()
+		}()
+		pool.worder = worder
+		pool.worder.Run()
+	} else {
+		pool.worder.RunWithRules()
+	}
 	if pool.Active {
 		pool.waiter.Add(1)
 		go pool.doActive()
@@ -187,6 +293,9 @@ func (pool *Pool) Run(offset, limit int) {
 	if pool.Bak {
 		pool.waiter.Add(1)
 		go pool.doBak()
+		pool.waiter.Add(1)
+		go pool.doContextBak()
+		pool.doArchive(pool.dir)
 	}
 
 	if pool.Common {
@@ -194,12 +303,39 @@ func (pool *Pool) Run(offset, limit int) {
 		go pool.doCommonFile()
 	}
 
+	if pool.Pack {
+		pool.doPack(pool.index)
+	}
+
+	if pool.JsAnalyze {
+		pool.doJs(pool.index)
+	}
+
+	if pool.API && pool.Mod == pkg.PathSpray && pool.Statistor.Depth == 0 {
+		pool.waiter.Add(1)
+		go pool.doAPISpec()
+	}
+
+	if pool.Seed && pool.Mod == pkg.PathSpray && pool.Statistor.Depth == 0 {
+		// robots.txt与sitemap位于站点根目录, 递归任务不需要重复请求
+		pool.waiter.Add(1)
+		go pool.doSeed()
+	}
+
+	wordCh := pool.worder.C
+	budget, reason := pool.budget()
 	var done bool
 	// 挂起一个监控goroutine, 每100ms判断一次done, 如果已经done, 则关闭closeCh, 然后通过Loop中的select case closeCh去break, 实现退出
 	go func() {
 		for {
 			if done {
 				pool.waiter.Wait()
+				for pool.clusters != nil && pool.clusters.Held() && pool.ctx.Err() == nil {
+					// 通知Handler输出聚类中暂存的结果, 输出的结果可能产生新的任务, 需要再次等待
+					pool.waiter.Add(1)
+					pool.tempCh <- nil
+					pool.waiter.Wait()
+				}
 				close(pool.closeCh)
 				return
 			}
@@ -210,11 +346,18 @@ func (pool *Pool) Run(offset, limit int) {
 Loop:
 	for {
 		select {
-		case w, ok := <-pool.worder.C:
+		case w, ok := <-wordCh:
 			if !ok {
 				done = true
 				continue
 			}
+			if pool.TargetRequests > 0 && int(atomic.LoadInt32(&pool.Statistor.ReqTotal)) >= pool.TargetRequests {
+				// 当前word没有发送, 断点续传时会重新发送
+				pool.stop("request budget")
+				wordCh = nil
+				done = true
+				continue
+			}
 			pool.Statistor.End++
 			pool.wordOffset++
 			if pool.wordOffset < offset {
@@ -226,12 +369,25 @@ Loop:
 				continue
 			}
 
+			if pool.Statistor.Completed.Contains(pool.wordOffset) {
+				// 断点续传时跳过已经完成的word
+				continue
+			}
+
+			if pool.Mod == pkg.PathSpray {
+				// 字典中出现了新的形态, 先校准再发送
+				pool.probeShapes(pkg.PathShape(w))
+			} else if pool.Mod == pkg.HostSpray {
+				// 第一次出现的上级域名, 使用同级的随机子域名校准
+				pool.probeParent(w)
+			}
+
 			pool.waiter.Add(1)
 			if pool.Mod == pkg.HostSpray {
-				pool.reqPool.Invoke(newUnitWithNumber(w, WordSource, pool.wordOffset))
+				pool.reqPool.Invoke(newWordUnit(w, pool.wordOffset))
 			} else {
 				// 原样的目录拼接, 输入了几个"/"就是几个, 适配/有语义的中间件
-				pool.reqPool.Invoke(newUnitWithNumber(pool.safePath(w), WordSource, pool.wordOffset))
+				pool.reqPool.Invoke(newWordUnit(pool.safePath(w), pool.wordOffset))
 			}
 
 		case source := <-pool.checkCh:
@@ -245,37 +401,95 @@ Loop:
 			if !ok || pool.closed {
 				continue
 			}
-			if _, ok := pool.urls[unit.path]; ok {
-				logs.Log.Debugf("[%s] duplicate path: %s, skipped", parsers.GetSpraySourceName(unit.source), pool.base+unit.path)
+			if pool.stopped {
+				// 已经停止分发, 插件任务保存到stat中用于断点续传
+				pool.savePending(unit)
+				pool.waiter.Done()
+				continue
+			}
+			if unit.source != RetrySource && !pool.Dedup.Add(unit.key(pool.base)) {
+				// 同一个host的url在所有pool之间共享去重
+				logs.Log.Debugf("[%s] duplicate path: %s, skipped", parsers.GetSpraySourceName(unit.source), unit.key(pool.base))
 				pool.waiter.Done()
 			} else {
-				pool.urls[unit.path] = struct{}{}
-				unit.number = pool.wordOffset
+				if !unit.word {
+					unit.number = pool.wordOffset
+				}
+				if pool.Mod == pkg.PathSpray && unit.source != RetrySource && unit.source != RedirectSource {
+					// 插件生成的路径可能位于更深的目录, 使用该目录自己的baseline
+					pool.probeDir(unit.path)
+				}
 				pool.reqPool.Invoke(unit)
 			}
+		case <-budget:
+			pool.stop(reason)
+			wordCh = nil
+			done = true
 		case <-pool.closeCh:
 			break Loop
 		case <-pool.ctx.Done():
 			break Loop
-		case <-pool.ctx.Done():
-			break Loop
 		}
 	}
 	pool.closed = true
+	if pool.ctx.Err() != nil {
+		// 任务被中断, 插件仍可能在生成新的任务, 等待所有生产者结束, 期间的任务保存到stat中
+		pool.savePendingAdditions()
+	}
 	pool.Close()
 }
 
+// budget 到达停止时间时触发的管道
+func (pool *Pool) budget() (<-chan time.Time, string) {
+	stopAt, reason := pool.stopTime()
+	if stopAt.IsZero() {
+		return nil, ""
+	}
+	return time.After(time.Until(stopAt)), reason
+}
+
+// stopTime 全局deadline与单个目标的时间预算, 返回更早到达的一个
+func (pool *Pool) stopTime() (time.Time, string) {
+	var stopAt time.Time
+	var reason string
+	if !pool.Deadline.IsZero() {
+		stopAt, reason = pool.Deadline, "deadline"
+	}
+	if pool.TargetTime > 0 {
+		t := time.Unix(pool.Statistor.StartTime, 0).Add(time.Duration(pool.TargetTime) * time.Second)
+		if stopAt.IsZero() || t.Before(stopAt) {
+			stopAt, reason = t, "time budget"
+		}
+	}
+	return stopAt, reason
+}
+
+// stop 停止分发新的word, 等待已经发出的请求处理完毕. 未发送的word与插件任务会保存到stat中, 可以通过--resume继续
+func (pool *Pool) stop(reason string) {
+	if pool.stopped {
+		return
+	}
+	pool.stopped = true
+	pool.Statistor.Stopped = reason
+	logs.Log.Importantf("[%s] %s reached, stop dispatching and drain in-flight requests. Breakpoint %d", pool.BaseURL, reason, pool.wordOffset)
+}
+
 func (pool *Pool) Invoke(v interface{}) {
+	unit := v.(*Unit)
+	if unit.probe == nil {
+		// 冷却期间暂停所有请求
+		pool.waitBan()
+	}
 	if pool.RateLimit != 0 {
 		pool.limiter.Wait(pool.ctx)
 	}
 
 	atomic.AddInt32(&pool.Statistor.ReqTotal, 1)
-	unit := v.(*Unit)
 
 	var req *ihttp.Request
 	var err error
-	if unit.source == WordSource {
+	if unit.source == WordSource || pool.Mod == pkg.HostSpray && (unit.source == CheckSource || unit.source == InitRandomSource) {
+		// host模式下的校准与check请求同样替换Host
 		req, err = pool.genReq(pool.Mod, unit.path)
 	} else {
 		req, err = pool.genReq(pkg.PathSpray, unit.path)
@@ -288,6 +502,12 @@ func (pool *Pool) Invoke(v interface{}) {
 
 	req.SetHeaders(pool.Headers)
 	req.SetHeader("User-Agent", RandomUA())
+	if unit.api != nil {
+		req.SetMethod(unit.api.Method)
+		if unit.api.Body != "" {
+			req.SetBody(unit.api.ContentType, []byte(unit.api.Body))
+		}
+	}
 
 	start := time.Now()
 	resp, reqerr := pool.client.Do(pool.ctx, req)
@@ -308,26 +528,44 @@ func (pool *Pool) Invoke(v interface{}) {
 				ErrString: reqerr.Error(),
 				Reason:    pkg.ErrRequestFailed.Error(),
 			},
+			ErrClass: ihttp.ClassifyError(reqerr),
 		}
+		pool.countError(bl.ErrClass)
 		pool.failedBaselines = append(pool.failedBaselines, bl)
-		// 自动重放失败请求, 默认为一次
-		pool.doRetry(bl)
+		// 根据错误类型自动重放失败请求, 默认为一次
+		pool.doRetry(unit, bl.ErrClass)
 
 	} else {
-		if unit.source <= 3 || unit.source == CrawlSource || unit.source == CommonFileSource {
+		if unit.source <= 3 || unit.source == CrawlSource || unit.source == CommonFileSource || unit.source == WafSource || unit.source == SeedSource || unit.source == ApiSource {
 			// 一些高优先级的source, 将跳过PreCompare
 			bl = pkg.NewBaseline(req.URI(), req.Host(), resp)
 		} else if pool.MatchExpr != nil {
 			// 如果自定义了match函数, 则所有数据送入tempch中
 			bl = pkg.NewBaseline(req.URI(), req.Host(), resp)
-		} else if err = pool.PreCompare(resp); err == nil {
+		} else if err = pool.PreCompare(unit.path, resp); err == nil {
 			// 通过预对比跳过一些无用数据, 减少性能消耗
 			bl = pkg.NewBaseline(req.URI(), req.Host(), resp)
+		} else if err == pkg.ErrWaf {
+			// 保留完整的响应, 用来识别waf厂商
+			bl = pkg.NewBaseline(req.URI(), req.Host(), resp)
+			bl.IsValid = false
+			bl.Reason = err.Error()
 		} else {
 			bl = pkg.NewInvalidBaseline(req.URI(), req.Host(), resp, err.Error())
 		}
 	}
 
+	if reqerr == fasthttp.ErrBodyTooLarge {
+		// 响应体超过上限不影响结果判断, 只做统计
+		bl.ErrClass = ihttp.ErrClassBodyTooLarge
+		pool.countError(bl.ErrClass)
+	}
+
+	if unit.word && bl.ErrString == "" {
+		// 只记录真正完成的word, 失败的word在断点续传时会重新发送
+		pool.Statistor.Completed.Add(unit.number)
+	}
+
 	// 手动处理重定向
 	if bl.IsValid && unit.source != CheckSource && bl.RedirectURL != "" {
 		//pool.waiter.Add(1)
@@ -337,17 +575,36 @@ func (pool *Pool) Invoke(v interface{}) {
 	if ihttp.DefaultMaxBodySize != 0 && bl.BodyLength > ihttp.DefaultMaxBodySize {
 		bl.ExceedLength = true
 	}
+	if pool.addr != "" {
+		bl.Address = pool.addr
+		if cert := resp.PeerCertificate(); cert != nil && bl.Url != nil {
+			bl.Cert = pkg.CertSummary(cert, bl.Url.Host)
+		}
+	}
 	bl.Source = unit.source
 	bl.ReqDepth = unit.depth
+	bl.RecuDepth = pool.Statistor.Depth
 	bl.Number = unit.number
 	bl.Spended = time.Since(start).Milliseconds()
+	bl.From = unit.from
+	bl.API = unit.api
+	if unit.probe != nil {
+		unit.probe <- bl
+		return
+	}
 	switch unit.source {
 	case InitRandomSource:
 		bl.Collect()
 		pool.locker.Lock()
-		pool.random = bl
-		pool.addFuzzyBaseline(bl)
+		if unit.shape != "" {
+			pool.shapes[unit.shape] = bl
+		} else {
+			pool.random = bl
+		}
 		pool.locker.Unlock()
+		if unit.shape == "" {
+			pool.addFuzzyBaseline(bl)
+		}
 		pool.initwg.Done()
 	case InitIndexSource:
 		bl.Collect()
@@ -361,10 +618,16 @@ func (pool *Pool) Invoke(v interface{}) {
 			pool.OutputCh <- bl
 		}
 		pool.initwg.Done()
+	case WafSource:
+		bl.Collect()
+		pool.locker.Lock()
+		pool.wafBaseline = bl
+		pool.locker.Unlock()
+		pool.initwg.Done()
 	case CheckSource:
 		if bl.ErrString != "" {
 			logs.Log.Warnf("[check.error] %s maybe ip had banned, break (%d/%d), error: %s", pool.BaseURL, pool.failedCount, pool.BreakThreshold, bl.ErrString)
-		} else if i := pool.random.Compare(bl); i < 1 {
+		} else if i := pool.getRandom().Compare(bl); i < 1 {
 			if i == 0 {
 				if pool.Fuzzy {
 					logs.Log.Warn("[check.fuzzy] maybe trigger risk control, " + bl.String())
@@ -373,6 +636,10 @@ func (pool *Pool) Invoke(v interface{}) {
 				atomic.AddInt32(&pool.failedCount, 1) //
 				logs.Log.Warn("[check.failed] maybe trigger risk control, " + bl.String())
 				pool.failedBaselines = append(pool.failedBaselines, bl)
+				if _, blocked := pool.detectBlock(bl); !blocked {
+					// 不是waf拦截页面, 可能是目标重新部署或者cdn规则变化导致404页面改变
+					pool.doDrift(bl)
+				}
 			}
 		} else {
 			pool.resetFailed() // 如果后续访问正常, 重置错误次数
@@ -430,6 +697,17 @@ func (pool *Pool) NoScopeInvoke(v interface{}) {
 
 func (pool *Pool) Handler() {
 	for bl := range pool.tempCh {
+		if bl == nil {
+			// 任务结束时仍未达到cluster-min的类不会再被标记, 暂存的成员作为有效结果输出
+			for _, member := range pool.clusters.ReleaseAll() {
+				pool.output(member, true, pool.exprParams(member))
+			}
+			pool.waiter.Done()
+			continue
+		}
+		if pool.hold(bl) {
+			continue
+		}
 		if bl.IsValid {
 			pool.addFuzzyBaseline(bl)
 		}
@@ -445,24 +723,16 @@ func (pool *Pool) Handler() {
 			pool.Statistor.Sources[bl.Source] = 1
 		}
 
-		var params map[string]interface{}
-		if pool.MatchExpr != nil || pool.FilterExpr != nil || pool.RecuExpr != nil {
-			params = map[string]interface{}{
-				"index":   pool.index,
-				"random":  pool.random,
-				"current": bl,
-			}
-			//for _, status := range FuzzyStatus {
-			//	if bl, ok := pool.baselines[status]; ok {
-			//		params["bl"+strconv.Itoa(status)] = bl
-			//	} else {
-			//		params["bl"+strconv.Itoa(status)] = nilBaseline
-			//	}
-			//}
-		}
+		params := pool.exprParams(bl)
 
 		var status bool
-		if pool.MatchExpr != nil {
+		if bl.API != nil {
+			// 接口文档中声明的接口一定存在, 只根据状态码分类, 不进行对比
+			status = pool.classifyAPI(bl)
+		} else if vendor, ok := pool.detectBlock(bl); ok {
+			// waf拦截的页面不会作为结果输出
+			pool.markWaf(bl, vendor)
+		} else if pool.MatchExpr != nil {
 			if CompareWithExpr(pool.MatchExpr, params) {
 				status = true
 			}
@@ -470,61 +740,141 @@ func (pool *Pool) Handler() {
 			status = pool.BaseCompare(bl)
 		}
 
-		if status {
-			pool.Statistor.FoundNumber++
-
-			// unique判断
-			if enableAllUnique || iutils.IntsContains(UniqueStatus, bl.Status) {
-				if _, ok := pool.uniques[bl.Unique]; ok {
-					bl.IsValid = false
-					bl.IsFuzzy = true
-					bl.Reason = pkg.ErrFuzzyNotUnique.Error()
-				} else {
-					pool.uniques[bl.Unique] = struct{}{}
-				}
+		if status && bl.API == nil {
+			fuzzy, held := pool.isSoft404(bl)
+			if held {
+				// 所属的类尚未确定是否为soft-404, 确定后再输出
+				pool.waiter.Done()
+				continue
+			}
+			if fuzzy {
+				status = false
 			}
+		}
+
+		pool.output(bl, status, params)
+		pool.waiter.Done()
+	}
+
+	pool.analyzeDone = true
+}
+
+// exprParams match, filter与recursive表达式的参数
+func (pool *Pool) exprParams(bl *pkg.Baseline) map[string]interface{} {
+	if pool.MatchExpr == nil && pool.FilterExpr == nil && pool.RecuExpr == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"index":   pool.getIndex(),
+		"random":  pool.getRandom(),
+		"current": bl,
+	}
+}
 
-			// 对通过所有对比的有效数据进行再次filter
-			if bl.IsValid && pool.FilterExpr != nil && CompareWithExpr(pool.FilterExpr, params) {
-				pool.Statistor.FilteredNumber++
-				bl.Reason = pkg.ErrCustomFilter.Error()
+// output 对比完成后的处理, 包括unique, filter, 插件与递归, 最后送入输出管道
+func (pool *Pool) output(bl *pkg.Baseline, status bool, params map[string]interface{}) {
+	if status {
+		pool.Statistor.FoundNumber++
+
+		// unique判断
+		if bl.API == nil && (enableAllUnique || iutils.IntsContains(UniqueStatus, bl.Status)) {
+			if _, ok := pool.uniques[bl.Unique]; ok {
 				bl.IsValid = false
+				bl.IsFuzzy = true
+				bl.Reason = pkg.ErrFuzzyNotUnique.Error()
+			} else {
+				pool.uniques[bl.Unique] = struct{}{}
 			}
-		} else {
-			bl.IsValid = false
 		}
 
-		if bl.IsValid || bl.IsFuzzy {
-			pool.waiter.Add(2)
-			pool.doCrawl(bl)
-			pool.doRule(bl)
+		// 对通过所有对比的有效数据进行再次filter
+		if bl.IsValid && pool.FilterExpr != nil && CompareWithExpr(pool.FilterExpr, params) {
+			pool.Statistor.FilteredNumber++
+			bl.Reason = pkg.ErrCustomFilter.Error()
+			bl.IsValid = false
 		}
-		// 如果要进行递归判断, 要满足 bl有效, mod为path-spray, 当前深度小于最大递归深度
-		if bl.IsValid {
-			if bl.RecuDepth < MaxRecursion {
-				if CompareWithExpr(pool.RecuExpr, params) {
-					bl.Recu = true
-				}
+	} else {
+		bl.IsValid = false
+	}
+
+	if bl.IsValid || bl.IsFuzzy {
+		pool.waiter.Add(2)
+		pool.doCrawl(bl)
+		pool.doRule(bl)
+	}
+	if bl.IsValid && pool.Pack {
+		pool.doPack(bl)
+	}
+	if bl.IsValid && pool.Bak && bl.IsDir() {
+		pool.doArchive(bl.Path)
+	}
+	if bl.IsValid && pool.Leak {
+		pool.doLeak(bl)
+	}
+	if bl.IsValid && pool.JsAnalyze {
+		pool.doJs(bl)
+	}
+	if bl.IsValid && pool.API {
+		pool.doAPI(bl)
+	}
+	if bl.IsValid && pool.Harvester != nil {
+		pool.waiter.Add(1)
+		pool.doHarvest(bl)
+	}
+	// 如果要进行递归判断, 要满足 bl有效, mod为path-spray, 当前深度小于最大递归深度
+	if bl.IsValid {
+		if bl.RecuDepth < pool.maxRecursion() {
+			if CompareWithExpr(pool.RecuExpr, params) {
+				bl.Recu = true
+				// 记录递归树, 断点续传时用来还原尚未开始的子任务
+				pool.locker.Lock()
+				pool.Statistor.Recursions = append(pool.Statistor.Recursions, bl.UrlString)
+				pool.locker.Unlock()
 			}
 		}
+	}
 
-		if !pool.closed {
-			// 如果任务被取消, 所有还没处理的请求结果都会被丢弃
-			pool.OutputCh <- bl
+	if bl.IsValid {
+		if pool.mirror != nil {
+			pool.mirror.AddResult(bl)
+		} else if pool.Statistor.MirrorOf != "" {
+			bl.Extracteds = append(bl.Extracteds, &parsers.Extracted{
+				Name:          "mirror",
+				ExtractResult: []string{pool.Statistor.MirrorOf},
+			})
+		}
+		if strings.HasPrefix(bl.From, "bak:") {
+			pool.countBak(strings.SplitN(bl.From, ":", 3)[1], 0, 1)
+		}
+		if bl.From != "" {
+			bl.Extracteds = append(bl.Extracteds, &parsers.Extracted{
+				Name:          "from",
+				ExtractResult: []string{bl.From},
+			})
+		}
+		if pool.Mod == pkg.HostSpray {
+			// 记录ip与虚拟主机的对应关系
+			pool.Statistor.Vhosts = append(pool.Statistor.Vhosts, pkg.NewVhost(bl))
 		}
-		pool.waiter.Done()
 	}
 
-	pool.analyzeDone = true
+	if !pool.closed {
+		// 如果任务被取消, 所有还没处理的请求结果都会被丢弃
+		pool.OutputCh <- bl
+	}
 }
 
-func (pool *Pool) PreCompare(resp *ihttp.Response) error {
+func (pool *Pool) PreCompare(path string, resp *ihttp.Response) error {
 	status := resp.StatusCode()
 	if iutils.IntsContains(WhiteStatus, status) {
 		// 如果为白名单状态码则直接返回
 		return nil
 	}
-	if pool.random.Status != 200 && pool.random.Status == status {
+	random := pool.getRandom()
+	if shape := pool.calibrated(path); shape != nil {
+		random = shape
+	}
+	if random.Status != 200 && random.Status == status {
 		return pkg.ErrSameStatus
 	}
 
@@ -555,17 +905,28 @@ func (pool *Pool) BaseCompare(bl *pkg.Baseline) bool {
 		return false
 	}
 
-	// 使用与baseline相同状态码, 需要在fuzzystatus中提前配置
-	base, ok := pool.baselines[bl.Status] // 挑选对应状态码的baseline进行compare
+	var base *pkg.Baseline
+	var ok bool
+	target := bl.Path
+	if pool.Mod == pkg.HostSpray {
+		target = bl.Url.Host
+	}
+	if shape := pool.calibrated(target); shape != nil && shape.Status == bl.Status {
+		// 优先使用与当前路径形态一致的baseline
+		base, ok = shape, true
+	} else {
+		// 使用与baseline相同状态码, 需要在fuzzystatus中提前配置
+		base, ok = pool.statusBaseline(bl.Status) // 挑选对应状态码的baseline进行compare
+	}
 	if !ok {
-		if pool.random.Status == bl.Status {
+		if random := pool.getRandom(); random.Status == bl.Status {
 			// 当other的状态码与base相同时, 会使用base
 			ok = true
-			base = pool.random
-		} else if pool.index.Status == bl.Status {
+			base = random
+		} else if index := pool.getIndex(); index.Status == bl.Status {
 			// 当other的状态码与index相同时, 会使用index
 			ok = true
-			base = pool.index
+			base = index
 		}
 	}
 
@@ -578,16 +939,15 @@ func (pool *Pool) BaseCompare(bl *pkg.Baseline) bool {
 
 	bl.Collect()
 
-	//if !pool.IgnoreWaf {
-	//	// 部分情况下waf的特征可能是全局, 指定了--ignore-waf则不会进行waf的指纹检测
-	//	for _, f := range bl.Frameworks {
-	//		if f.HasTag("waf") {
-	//			pool.Statistor.WafedNumber++
-	//			bl.Reason = ErrWaf.Error()
-	//			return false
-	//		}
-	//	}
-	//}
+	if !pool.IgnoreWaf {
+		// 部分情况下waf的特征可能是全局, index与random中同样存在的waf指纹不作为拦截的依据
+		for _, f := range bl.Frameworks {
+			if f.HasTag("waf") && !pool.globalWaf(f.Name) {
+				pool.markWaf(bl, f.Name)
+				return false
+			}
+		}
+	}
 
 	if ok && status == 0 && base.FuzzyCompare(bl) {
 		pool.Statistor.FuzzyNumber++
@@ -599,12 +959,227 @@ func (pool *Pool) BaseCompare(bl *pkg.Baseline) bool {
 	return true
 }
 
+// probeShapes 为尚未校准的路径形态发送随机路径请求, 与InitRandom一样同步等待结果
+func (pool *Pool) probeShapes(shapes ...string) {
+	var units []*Unit
+	pool.locker.Lock()
+	for _, shape := range shapes {
+		if shape == "" || shape == "*" || pool.shapeCount >= MaxShapes {
+			// "*"即pool.random
+			continue
+		}
+		if _, ok := pool.shapes[shape]; ok {
+			continue
+		}
+		pool.shapes[shape] = nil // 占位, 防止重复校准
+		pool.shapeCount++
+		units = append(units, &Unit{path: pool.safePath(pkg.RandShapePath(shape)), source: InitRandomSource, shape: shape})
+	}
+	pool.locker.Unlock()
+	pool.calibrate(units)
+}
+
+// probeDir 插件第一次请求起始目录之外的目录时, 使用该目录下同形态的随机路径校准.
+// 例如/api/下返回json格式的404, 而/下返回html格式的404
+func (pool *Pool) probeDir(p string) {
+	dir, shape := splitShape(p)
+	if shape == "" || dir == pool.safePath("") {
+		return
+	}
+	key := dir + shape
+	pool.locker.Lock()
+	if _, ok := pool.shapes[key]; ok || pool.dirCount >= MaxDirs {
+		pool.locker.Unlock()
+		return
+	}
+	pool.shapes[key] = nil
+	pool.dirCount++
+	pool.locker.Unlock()
+	pool.calibrate([]*Unit{{path: dir + pkg.RandShapePath(shape), source: InitRandomSource, shape: key}})
+}
+
+// probeParent host模式下, 不存在的子域名通常会落到同一个默认站点, 但不同的上级域名可能有各自的泛解析站点.
+// 因此使用同一个上级域名下的随机子域名校准
+func (pool *Pool) probeParent(host string) {
+	parent := pkg.ParentDomain(host)
+	if parent == "" {
+		return
+	}
+	key := "*." + parent
+	pool.locker.Lock()
+	if _, ok := pool.shapes[key]; ok || pool.dirCount >= MaxDirs {
+		pool.locker.Unlock()
+		return
+	}
+	pool.shapes[key] = nil
+	pool.dirCount++
+	pool.locker.Unlock()
+	pool.calibrate([]*Unit{{path: pkg.RandSubdomain(parent), source: InitRandomSource, shape: key}})
+}
+
+func (pool *Pool) calibrate(units []*Unit) {
+	if len(units) == 0 {
+		return
+	}
+	pool.initwg.Add(len(units))
+	for _, unit := range units {
+		pool.reqPool.Invoke(unit)
+	}
+	pool.initwg.Wait()
+	for _, unit := range units {
+		if bl := pool.calibrated(unit.path); bl != nil {
+			logs.Log.Infof("[baseline.%s] %s", unit.shape, bl.Format([]string{"status", "length", "spend", "title", "frame", "redirect"}))
+		}
+	}
+}
+
+// calibrated 获取与路径所在目录及形态一致的baseline, 优先使用目录的校准结果, 其次是起始目录下同形态的校准结果.
+// 没有校准过或者校准失败时返回nil
+func (pool *Pool) calibrated(p string) *pkg.Baseline {
+	if pool.Mod == pkg.HostSpray {
+		pool.locker.Lock()
+		defer pool.locker.Unlock()
+		if bl, ok := pool.shapes["*."+pkg.ParentDomain(p)]; ok && bl != nil && bl.ErrString == "" {
+			return bl
+		}
+		return nil
+	} else if pool.Mod != pkg.PathSpray {
+		return nil
+	}
+	dir, shape := splitShape(p)
+	pool.locker.Lock()
+	defer pool.locker.Unlock()
+	if bl, ok := pool.shapes[dir+shape]; ok && bl != nil && bl.ErrString == "" {
+		return bl
+	}
+	if bl, ok := pool.shapes[pkg.PathShape(strings.TrimPrefix(p, pool.safePath("")))]; ok && bl != nil && bl.ErrString == "" {
+		return bl
+	}
+	return nil
+}
+
+// isSoft404 将通过对比的响应加入聚类, 如果所属的类在所有有效结果中占比过高, 判定为soft-404.
+// 类的数量达到cluster-min之前暂存成员, 确定后暂存的成员统一输出为有效结果或者soft-404, 同一个结果只会输出一次
+func (pool *Pool) isSoft404(bl *pkg.Baseline) (fuzzy bool, held bool) {
+	if pool.clusters == nil {
+		return false, false
+	}
+	c, held, marked := pool.clusters.Add(bl)
+	if c == nil {
+		return false, false
+	}
+	bl.Cluster = c.ID
+	if held {
+		return false, true
+	}
+	if marked {
+		logs.Log.Importantf("[cluster.%d] %d/%d responses in the same cluster, mark as soft-404, %s", c.ID, c.Count, pool.clusters.Total(), bl.Format([]string{"status", "length", "title"}))
+	}
+	for _, member := range pool.clusters.Release(c) {
+		if c.Fuzzy {
+			pool.putToCluster(member)
+			pool.output(member, false, nil)
+		} else {
+			pool.output(member, true, pool.exprParams(member))
+		}
+	}
+	if !c.Fuzzy {
+		return false, false
+	}
+	pool.putToCluster(bl)
+	return true, false
+}
+
+func (pool *Pool) putToCluster(bl *pkg.Baseline) {
+	pool.Statistor.FuzzyNumber++
+	bl.IsValid = false
+	bl.Reason = pkg.ErrFuzzyCluster.Error()
+	pool.putToFuzzy(bl)
+}
+
+// initWaf 发送一个携带常见攻击payload的请求, 如果被拦截, 记录拦截页面与waf厂商, 用来识别后续请求中的拦截页面
+func (pool *Pool) initWaf() {
+	pool.wafBaseline = nil
+	pool.initwg.Add(1)
+	pool.reqPool.Invoke(newUnit(pool.safePath(pkg.RandPath())+pkg.WafPayload, WafSource))
+	pool.initwg.Wait()
+
+	bl := pool.wafBaseline
+	if bl.ErrString != "" || (bl.Status == pool.random.Status && pool.random.Compare(bl) == 1) {
+		// 连接被重置无法作为拦截页面的特征; 与random一致说明payload没有被拦截
+		pool.wafBaseline = nil
+		return
+	}
+	vendor := pkg.BlockPage(bl.Status, bl.Body)
+	if vendor == "" && iutils.IntsContains(pkg.BlockStatus, bl.Status) {
+		vendor = pkg.WafVendor(bl.Header)
+	}
+	if vendor == "" && !iutils.IntsContains(pkg.BlockStatus, bl.Status) {
+		pool.wafBaseline = nil
+		return
+	}
+	bl.Waf = vendor
+	pool.Statistor.Waf = vendor
+	logs.Log.Infof("[baseline.waf] %s %s", vendor, bl.Format([]string{"status", "length", "spend", "title", "frame"}))
+}
+
+// detectBlock 通过拦截页面的特征, 初始化时得到的拦截页面, 以及拦截状态码下的waf厂商特征判断是否被waf拦截
+func (pool *Pool) detectBlock(bl *pkg.Baseline) (string, bool) {
+	if pool.IgnoreWaf || !bl.IsValid && bl.Reason != pkg.ErrWaf.Error() {
+		return "", false
+	}
+	if vendor := pkg.BlockPage(bl.Status, bl.Body); vendor != "" {
+		return vendor, true
+	}
+	if pool.wafBaseline != nil && pool.wafBaseline.Status == bl.Status && pool.wafBaseline.Compare(bl) == 1 {
+		return pool.wafBaseline.Waf, true
+	}
+	if iutils.IntsContains(pkg.BlockStatus, bl.Status) {
+		// 目标整体部署在cdn或waf之后时, 所有响应都携带厂商的header, 只有index与random中没有的厂商特征才能说明被拦截
+		if vendor := pkg.WafVendor(bl.Header); vendor != "" && !pool.globalWaf(vendor) {
+			return vendor, true
+		}
+	}
+	if bl.Reason == pkg.ErrWaf.Error() {
+		return pool.Statistor.Waf, true
+	}
+	return "", false
+}
+
+// globalWaf index或random的指纹与header中已经存在该waf, 说明是全局的特征
+func (pool *Pool) globalWaf(name string) bool {
+	for _, base := range []*pkg.Baseline{pool.getIndex(), pool.getRandom()} {
+		if base == nil {
+			continue
+		}
+		if _, ok := base.Frameworks[name]; ok {
+			return true
+		}
+		if pkg.WafVendor(base.Header) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (pool *Pool) markWaf(bl *pkg.Baseline, vendor string) {
+	pool.Statistor.WafedNumber++
+	if pool.Statistor.Waf == "" {
+		pool.Statistor.Waf = vendor
+	}
+	bl.Waf = vendor
+	bl.IsValid = false
+	bl.Reason = pkg.ErrWaf.Error()
+}
+
 func (pool *Pool) Upgrade(bl *pkg.Baseline) error {
 	rurl, err := url.Parse(bl.RedirectURL)
 	if err == nil && rurl.Hostname() == bl.Url.Hostname() && bl.Url.Scheme == "http" && rurl.Scheme == "https" {
 		logs.Log.Infof("baseurl %s upgrade http to https, reinit", pool.BaseURL)
 		pool.base = strings.Replace(pool.BaseURL, "http", "https", 1)
 		pool.url.Scheme = "https"
+		pool.shapes = make(map[string]*pkg.Baseline)
+		pool.shapeCount, pool.dirCount = 0, 0
 		// 重新初始化
 		err = pool.Init()
 		if err != nil {
@@ -675,13 +1250,10 @@ func (pool *Pool) doScopeCrawl(bl *pkg.Baseline) {
 				if v, _ := url.Parse(u); v == nil || !MatchWithGlobs(v.Host, pool.Scope) {
 					continue
 				}
-				pool.scopeLocker.Lock()
-				if _, ok := pool.scopeurls[u]; !ok {
-					pool.urls[u] = struct{}{}
+				if pool.Dedup.Add(u) {
 					pool.waiter.Add(1)
 					pool.scopePool.Invoke(&Unit{path: u, source: CrawlSource, depth: bl.ReqDepth + 1})
 				}
-				pool.scopeLocker.Unlock()
 			}
 		}
 	}()
@@ -708,33 +1280,360 @@ func (pool *Pool) doRule(bl *pkg.Baseline) {
 	}()
 }
 
-func (pool *Pool) doRetry(bl *pkg.Baseline) {
-	if bl.Retry >= pool.Retry {
+// doLeak 命中.git/, .svn/, .hg/, WEB-INF/下的路径时请求其中的元数据文件; 命中元数据文件时解析出其中所有的文件路径
+func (pool *Pool) doLeak(bl *pkg.Baseline) {
+	if pool.Mod != pkg.PathSpray || bl.Url == nil {
+		return
+	}
+	var units []*Unit
+	if bl.Status == 200 {
+		if leak := pkg.ParseLeak(bl.Url.Path, bl.Body); leak != nil {
+			logs.Log.Importantf("[leak.%s] %s reveals %d paths", leak.Name, bl.UrlString, len(leak.Paths))
+			for _, p := range leak.Paths {
+				units = append(units, &Unit{path: leak.Root + p, source: LeakSource, from: "leak:" + leak.Name + ":" + bl.Url.Path})
+			}
+		}
+	}
+	if root, files := pkg.LeakFiles(bl.Url.Path); root != "" {
+		pool.locker.Lock()
+		if !pool.leaks[root+files[0]] {
+			pool.leaks[root+files[0]] = true
+			for _, file := range files {
+				units = append(units, &Unit{path: root + file, source: LeakSource, from: "leak:" + bl.Url.Path})
+			}
+		}
+		pool.locker.Unlock()
+	}
+	if len(units) == 0 {
 		return
 	}
 	pool.waiter.Add(1)
 	go func() {
 		defer pool.waiter.Done()
-		pool.addAddition(&Unit{
-			path:   bl.Path,
-			source: RetrySource,
-			retry:  bl.Retry + 1,
-		})
+		for _, unit := range units {
+			pool.addAddition(unit)
+		}
 	}()
 }
 
-func (pool *Pool) doActive() {
-	defer pool.waiter.Done()
-	for _, u := range pkg.ActivePath {
-		pool.addAddition(&Unit{
-			path:   pool.dir + u[1:],
-			source: ActiveSource,
-		})
+// doJs html中引用的js, js中import的js, sourcemap与webpack chunk作为新的任务; js与sourcemap中的api路径拼接baseURL后加入任务,
+// baseURL, 参数名与还原的源文件名记录在结果中
+func (pool *Pool) doJs(bl *pkg.Baseline) {
+	if pool.Mod != pkg.PathSpray || bl == nil || bl.Url == nil || len(bl.Body) == 0 {
+		return
 	}
-}
-
-func (pool *Pool) doBak() {
-	defer pool.waiter.Done()
+	var scripts, apis []string
+	var analysis *pkg.JsAnalysis
+	if strings.HasSuffix(bl.Url.Path, ".map") {
+		analysis = pkg.AnalyzeSourceMap(bl.Body)
+	} else if strings.HasSuffix(bl.Url.Path, ".js") || bl.ContentType == "js" {
+		analysis = pkg.AnalyzeJs(bl.Url.Path, bl.Body)
+	} else if bl.ContentType == "html" {
+		scripts = pkg.ScriptURLs(bl.Body)
+	}
+	if analysis != nil {
+		scripts = append(analysis.Scripts, analysis.Chunks...)
+		apis = analysis.WithBaseURLs()
+		for _, extracted := range []*parsers.Extracted{
+			{Name: "js.api", ExtractResult: analysis.APIs},
+			{Name: "js.base", ExtractResult: analysis.BaseURLs},
+			{Name: "js.params", ExtractResult: analysis.Params},
+			{Name: "js.chunks", ExtractResult: analysis.Chunks},
+			{Name: "js.sources", ExtractResult: analysis.Sources},
+		} {
+			if len(extracted.ExtractResult) > 0 {
+				bl.Extracteds = append(bl.Extracteds, extracted)
+			}
+		}
+	}
+
+	var units []*Unit
+	for _, u := range append(scripts, apis...) {
+		if p := pool.jsPath(bl.Url.Path, u); p != "" {
+			units = append(units, &Unit{path: p, source: JsSource, from: "js:" + bl.Url.Path})
+		}
+	}
+	pool.locker.Lock()
+	if pool.jsPaths+len(units) > MaxJsPaths {
+		units = units[:MaxJsPaths-pool.jsPaths]
+	}
+	pool.jsPaths += len(units)
+	pool.locker.Unlock()
+	if len(units) == 0 {
+		return
+	}
+	pool.waiter.Add(1)
+	go func() {
+		defer pool.waiter.Done()
+		for _, unit := range units {
+			pool.addAddition(unit)
+		}
+	}()
+}
+
+// jsPath 将js中的url转换为当前站点的路径, 其他站点的url返回空
+func (pool *Pool) jsPath(base, u string) string {
+	if strings.HasPrefix(u, "http") || strings.HasPrefix(u, "//") {
+		parsed, err := url.Parse(u)
+		if err != nil || parsed.Host != pool.url.Host {
+			return ""
+		}
+		return parsed.RequestURI()
+	}
+	return FormatURL(base, u)
+}
+
+// doAPISpec 请求常见的接口文档与graphql地址, 命中后由doAPI解析
+func (pool *Pool) doAPISpec() {
+	defer pool.waiter.Done()
+	for _, p := range pkg.APISpecPaths {
+		pool.addAddition(&Unit{
+			path:   p,
+			source: ApiSource,
+			from:   "api:spec",
+		})
+	}
+}
+
+// doAPI 解析swagger/openapi文档与springfox的swagger-resources, 将声明的所有接口加入任务; 命中graphql接口时发送内省查询
+func (pool *Pool) doAPI(bl *pkg.Baseline) {
+	if pool.Mod != pkg.PathSpray || bl.Url == nil {
+		return
+	}
+	graphql := pkg.IsGraphQL(bl.Url.Path)
+	resources := strings.HasSuffix(bl.Url.Path, "/swagger-resources")
+	// GET请求graphql通常返回400或405, 只要结果有效就尝试内省
+	if !graphql && (bl.Status != 200 || !resources && !pkg.IsAPISpec(bl.Url.Path) && !pkg.IsAPISpecContent(bl.Body)) {
+		return
+	}
+	p := bl.Url.RequestURI()
+	pool.locker.Lock()
+	if pool.apis[p] {
+		pool.locker.Unlock()
+		return
+	}
+	pool.apis[p] = true
+	pool.locker.Unlock()
+
+	if graphql {
+		pool.waiter.Add(1)
+		go pool.introspect(bl.Url.Path)
+		return
+	}
+
+	var units []*Unit
+	if resources {
+		for _, location := range pkg.ParseSwaggerResources(bl.Body) {
+			if location = pool.jsPath(bl.Url.Path, location); location != "" {
+				units = append(units, &Unit{path: location, source: ApiSource, from: "api:" + p})
+			}
+		}
+	} else {
+		if bl.ExceedLength {
+			logs.Log.Warnf("[api] %s exceeds max body size, spec may be truncated, use --read-all", bl.UrlString)
+		}
+		endpoints := pkg.ParseAPISpec(bl.Body)
+		if len(endpoints) > 0 {
+			logs.Log.Importantf("[api] %s declares %d endpoints", bl.UrlString, len(endpoints))
+		}
+		for _, api := range pool.filterAPI(bl, endpoints) {
+			units = append(units, &Unit{path: api.Path, source: ApiSource, from: "api:" + p, api: api})
+		}
+	}
+	pool.addAPIUnits(units)
+}
+
+// filterAPI 未指定--api-unsafe时, 跳过可能修改数据的接口, 这些接口记录在文档的结果与stat中, 作为已声明但未测试的接口
+func (pool *Pool) filterAPI(bl *pkg.Baseline, endpoints []*pkg.APIEndpoint) []*pkg.APIEndpoint {
+	if pool.APIUnsafe {
+		return endpoints
+	}
+	var safe []*pkg.APIEndpoint
+	var untested []string
+	for _, api := range endpoints {
+		if api.Unsafe {
+			untested = append(untested, api.Raw)
+		} else {
+			safe = append(safe, api)
+		}
+	}
+	if len(untested) == 0 {
+		return safe
+	}
+	logs.Log.Importantf("[api] %s %d state-changing endpoints declared but untested, use --api-unsafe to send them", bl.UrlString, len(untested))
+	bl.Extracteds = append(bl.Extracteds, &parsers.Extracted{
+		Name:          "api.untested",
+		ExtractResult: untested,
+	})
+	pool.locker.Lock()
+	pool.Statistor.APIClasses["untested"] += len(untested)
+	pool.Statistor.APIUntested = append(pool.Statistor.APIUntested, untested...)
+	pool.locker.Unlock()
+	return safe
+}
+
+// introspect 向graphql接口发送内省查询, 将每个query与mutation作为接口加入任务. 内省请求本身同样作为结果输出
+func (pool *Pool) introspect(p string) {
+	defer pool.waiter.Done()
+	bl := pool.probe(&Unit{
+		path:   p,
+		source: ApiSource,
+		from:   "api:" + p,
+		api: &pkg.APIEndpoint{
+			Method:      "POST",
+			Path:        p,
+			Body:        pkg.GraphQLIntrospection,
+			ContentType: "application/json",
+			Raw:         "POST " + p + " introspection",
+		},
+	})
+	if bl == nil {
+		return
+	}
+	var units []*Unit
+	if bl.ErrString == "" && bl.Status == 200 {
+		if endpoints := pkg.ParseGraphQL(p, bl.Body); len(endpoints) > 0 {
+			logs.Log.Importantf("[api] %s%s introspection enabled, %d operations", pool.base, p, len(endpoints))
+			for _, api := range pool.filterAPI(bl, endpoints) {
+				units = append(units, &Unit{path: p, source: ApiSource, from: "api:" + p, api: api})
+			}
+		}
+	}
+	// 解析之后再送入Handler, 保证未测试的mutation记录在内省请求的结果中
+	pool.waiter.Add(1)
+	pool.tempCh <- bl
+	pool.addAPIUnits(units)
+}
+
+func (pool *Pool) addAPIUnits(units []*Unit) {
+	if len(units) == 0 {
+		return
+	}
+	pool.waiter.Add(1)
+	go func() {
+		defer pool.waiter.Done()
+		for _, unit := range units {
+			pool.addAddition(unit)
+		}
+	}()
+}
+
+// classifyAPI 接口文档中声明的接口按响应分类, 401/403/405同样说明接口存在, 作为有效结果输出
+func (pool *Pool) classifyAPI(bl *pkg.Baseline) bool {
+	extracteds := bl.Extracteds
+	bl.Collect()
+	bl.Extracteds = append(bl.Extracteds, extracteds...)
+	bl.APIClass = pkg.APIClass(bl.Status)
+	pool.locker.Lock()
+	pool.Statistor.APIClasses[bl.APIClass]++
+	pool.locker.Unlock()
+	bl.Extracteds = append(bl.Extracteds, &parsers.Extracted{
+		Name:          "api",
+		ExtractResult: []string{bl.API.Raw + " -> " + bl.APIClass},
+	})
+	if bl.APIClass == "other" {
+		bl.Reason = pkg.ErrBadStatus.Error()
+		return false
+	}
+	return true
+}
+
+// doHarvest 从有效结果中收集单词, 在结果所在的目录下爆破. 收集到的结果不再继续收集, 避免字典无限膨胀
+func (pool *Pool) doHarvest(bl *pkg.Baseline) {
+	if pool.Mod != pkg.PathSpray || bl.Source == HarvestSource || bl.Url == nil {
+		pool.waiter.Done()
+		return
+	}
+	harvests := pool.Harvester.Harvest(bl)
+	if len(harvests) == 0 {
+		pool.waiter.Done()
+		return
+	}
+	logs.Log.Debugf("[harvest] %s harvested %d words", bl.UrlString, len(harvests))
+	dir := Dir(bl.Url.Path)
+	go func() {
+		defer pool.waiter.Done()
+		for _, w := range harvests {
+			pool.addAddition(&Unit{
+				path:   dir + w,
+				source: HarvestSource,
+				from:   "harvest:" + bl.Url.Path,
+			})
+		}
+	}()
+}
+
+func (pool *Pool) doRetry(unit *Unit, class string) {
+	if unit.retry >= pool.retryCount(class) || unit.probe != nil {
+		return
+	}
+	retry := unit.retry + 1
+	if pool.isBanned() {
+		// 封禁期间失败的请求不计入重试次数, 冷却结束后重新发送
+		retry = unit.retry
+	}
+	pool.waiter.Add(1)
+	go func() {
+		defer pool.waiter.Done()
+		select {
+		case <-time.After(pool.backoff(unit.retry)):
+		case <-pool.ctx.Done():
+		}
+		pool.addAddition(&Unit{
+			path:   unit.path,
+			source: RetrySource,
+			retry:  retry,
+			number: unit.number,
+			word:   unit.word,
+			from:   unit.from,
+			api:    unit.api,
+		})
+	}()
+}
+
+// retryCount 每种错误类型可以单独配置重试次数, 未配置的使用--retry
+func (pool *Pool) retryCount(class string) int {
+	if count, ok := pool.RetryPolicy[class]; ok {
+		return count
+	}
+	return pool.Retry
+}
+
+// backoff 指数退避, 并增加最多一半的随机抖动, 避免大量失败的请求同时重放
+func (pool *Pool) backoff(retry int) time.Duration {
+	if pool.RetryBackoff <= 0 {
+		return 0
+	}
+	delay := time.Duration(pool.RetryBackoff) * time.Millisecond << uint(retry)
+	if delay > MaxBackoff || delay <= 0 {
+		delay = MaxBackoff
+	}
+	delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	if stopAt, _ := pool.stopTime(); !stopAt.IsZero() && time.Until(stopAt) < delay {
+		// 退避不超过剩余时间, 到达停止时间后重试任务直接保存到stat中
+		delay = time.Until(stopAt)
+	}
+	return delay
+}
+
+func (pool *Pool) countError(class string) {
+	pool.locker.Lock()
+	defer pool.locker.Unlock()
+	pool.Statistor.Errors[class]++
+}
+
+func (pool *Pool) doActive() {
+	defer pool.waiter.Done()
+	for _, u := range pkg.ActivePath {
+		pool.addAddition(&Unit{
+			path:   pool.dir + u[1:],
+			source: ActiveSource,
+		})
+	}
+}
+
+func (pool *Pool) doBak() {
+	defer pool.waiter.Done()
 	worder, err := words.NewWorderWithDsl("{?0}.{@bak_ext}", [][]string{pkg.BakGenerator(pool.url.Host)}, nil)
 	if err != nil {
 		return
@@ -760,6 +1659,143 @@ func (pool *Pool) doBak() {
 	}
 }
 
+// doArchive 为有效的目录生成上级目录中的打包文件, 例如/a/b/ 生成 /a/b.zip, /a/b.tar.gz
+func (pool *Pool) doArchive(dir string) {
+	if pool.Mod != pkg.PathSpray {
+		return
+	}
+	pool.locker.Lock()
+	if pool.archives[dir] {
+		pool.locker.Unlock()
+		return
+	}
+	pool.archives[dir] = true
+	pool.locker.Unlock()
+	candidates := pkg.ArchiveCandidates(dir)
+	if len(candidates) == 0 {
+		return
+	}
+	pool.countBak("dir", len(candidates), 0)
+	pool.waiter.Add(1)
+	go func() {
+		defer pool.waiter.Done()
+		for _, u := range candidates {
+			pool.addAddition(&Unit{
+				path:   u,
+				source: BakSource,
+				from:   "bak:dir:" + dir,
+			})
+		}
+	}()
+}
+
+// doContextBak 根据站点名, index的标题, 页面中的年份与日期生成备份文件
+func (pool *Pool) doContextBak() {
+	defer pool.waiter.Done()
+	names := pkg.ContextBakNames(pool.getIndex(), pool.url.Host, time.Now())
+	pool.countBak("context", len(names)*len(pkg.ArchiveExtensions), 0)
+	for _, name := range names {
+		for _, ext := range pkg.ArchiveExtensions {
+			pool.addAddition(&Unit{
+				path:   pool.dir + name + "." + ext,
+				source: BakSource,
+				from:   "bak:context:" + name,
+			})
+		}
+	}
+}
+
+// countBak 按类型统计生成与命中的备份文件
+func (pool *Pool) countBak(kind string, generated, found int) {
+	pool.locker.Lock()
+	defer pool.locker.Unlock()
+	pool.Statistor.BakGenerated[kind] += generated
+	pool.Statistor.BakFound[kind] += found
+}
+
+// doSeed 从robots.txt, sitemap与/.well-known/中获取路径, 包括Disallow的路径. 这些路径的结果同样会进入crawl与递归
+func (pool *Pool) doSeed() {
+	defer pool.waiter.Done()
+	var paths, sitemaps []string
+	if bl := pool.seed("/robots.txt"); bl != nil {
+		paths, sitemaps = pkg.ParseRobots(bl.Body)
+		logs.Log.Importantf("[seed] %s/robots.txt, %d paths, %d sitemaps", pool.base, len(paths), len(sitemaps))
+	}
+	if len(sitemaps) == 0 {
+		sitemaps = pkg.SitemapPaths
+	}
+
+	var fetched int
+	visited := make(map[string]bool)
+	for i := 0; i < len(sitemaps) && fetched < MaxSitemaps; i++ {
+		p := pool.seedPath(sitemaps[i])
+		if p == "" || visited[p] {
+			continue
+		}
+		visited[p] = true
+		fetched++
+		bl := pool.seed(p)
+		if bl == nil {
+			continue
+		}
+		urls, subs := pkg.ParseSitemap(bl.Body)
+		logs.Log.Importantf("[seed] %s%s, %d urls, %d sitemaps", pool.base, p, len(urls), len(subs))
+		sitemaps = append(sitemaps, subs...)
+		for _, u := range urls {
+			if p := pool.seedPath(u); p != "" {
+				paths = append(paths, p)
+			}
+		}
+	}
+
+	paths = pkg.RemoveDuplication(paths)
+	if len(paths) > MaxSeeds {
+		logs.Log.Warnf("[seed] %s found %d paths, only the first %d will be sprayed", pool.base, len(paths), MaxSeeds)
+		paths = paths[:MaxSeeds]
+	}
+	for _, p := range paths {
+		pool.addAddition(&Unit{
+			path:   p,
+			source: SeedSource,
+		})
+	}
+	for _, p := range pkg.WellKnownPaths {
+		pool.addAddition(&Unit{
+			path:   "/.well-known/" + p,
+			source: SeedSource,
+		})
+	}
+}
+
+// seed 直接请求robots.txt或sitemap并返回响应用于解析, 响应同样作为结果送入Handler
+func (pool *Pool) seed(p string) *pkg.Baseline {
+	if !pool.Dedup.Add(pool.base + p) {
+		return nil
+	}
+	bl := pool.probe(&Unit{path: p, source: SeedSource})
+	if bl == nil {
+		return nil
+	}
+	pool.waiter.Add(1)
+	pool.tempCh <- bl
+	if bl.ErrString != "" || bl.Status != 200 {
+		return nil
+	}
+	return bl
+}
+
+// seedPath 将robots.txt与sitemap中的url转换为当前站点的路径, 其他站点的url返回空
+func (pool *Pool) seedPath(u string) string {
+	if strings.HasPrefix(u, "/") {
+		return u
+	}
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host != pool.url.Host {
+		return ""
+	}
+	return parsed.RequestURI()
+}
+
 func (pool *Pool) doCommonFile() {
 	defer pool.waiter.Done()
 	for _, u := range mask.SpecialWords["common_file"] {
@@ -770,7 +1806,43 @@ func (pool *Pool) doCommonFile() {
 	}
 }
 
+// doPack 根据指纹加入对应框架的路径包, 路径相对于指纹所在的目录. 同一个目录下每个路径包只加入一次
+func (pool *Pool) doPack(bl *pkg.Baseline) {
+	if pool.Mod != pkg.PathSpray || bl == nil || bl.Url == nil || len(bl.Frameworks) == 0 {
+		return
+	}
+	dir := Dir(bl.Url.Path)
+	for pack, framework := range pkg.MatchPacks(bl.Frameworks) {
+		pool.locker.Lock()
+		if pool.packs[dir+pack.Name] {
+			pool.locker.Unlock()
+			continue
+		}
+		pool.packs[dir+pack.Name] = true
+		pool.locker.Unlock()
+
+		logs.Log.Importantf("[pack] %s%s detected %s, add %d paths of pack %s", pool.base, dir, framework, len(pack.Paths), pack.Name)
+		from := "pack:" + pack.Name + "(" + framework + ")"
+		pool.waiter.Add(1)
+		go func(pack *pkg.Pack) {
+			defer pool.waiter.Done()
+			for _, p := range pack.Paths {
+				pool.addAddition(&Unit{
+					path:   dir + strings.TrimPrefix(p, "/"),
+					source: PackSource,
+					from:   from,
+				})
+			}
+		}(pack)
+	}
+}
+
 func (pool *Pool) doCheck() {
+	if pool.failedCount > pool.BreakThreshold && pool.CoolDown > 0 {
+		// 暂停任务, 冷却后重新check
+		pool.coolDown()
+		return
+	}
 	if pool.failedCount > pool.BreakThreshold {
 		// 当报错次数超过上限是, 结束任务
 		pool.recover()
@@ -786,6 +1858,220 @@ func (pool *Pool) doCheck() {
 	}
 }
 
+func (pool *Pool) isBanned() bool {
+	pool.banLocker.Lock()
+	defer pool.banLocker.Unlock()
+	return pool.banCh != nil
+}
+
+func (pool *Pool) waitBan() {
+	pool.banLocker.Lock()
+	ch := pool.banCh
+	pool.banLocker.Unlock()
+	if ch == nil {
+		return
+	}
+	select {
+	case <-ch:
+	case <-pool.ctx.Done():
+	}
+}
+
+// coolDown 疑似被封禁时暂停所有请求, 以指数增长的间隔重新check, random baseline恢复一致后继续.
+// 所有冷却的总时长超过MaxBanTime后放弃该任务
+func (pool *Pool) coolDown() {
+	pool.banLocker.Lock()
+	if pool.banCh != nil {
+		pool.banLocker.Unlock()
+		return
+	}
+	pool.banCh = make(chan struct{})
+	pool.banLocker.Unlock()
+
+	ban := &pkg.Ban{Start: time.Now().Unix()}
+	if len(pool.failedBaselines) > 0 {
+		last := pool.failedBaselines[len(pool.failedBaselines)-1]
+		ban.Reason = last.ErrString
+		if ban.Reason == "" {
+			ban.Reason = last.Format([]string{"status", "length", "title"})
+		}
+	}
+	go func() {
+		banTime := pool.Statistor.BanTime()
+		wait := pool.CoolDown
+		for {
+			elapsed := time.Now().Unix() - ban.Start
+			if banTime+elapsed+int64(wait) > int64(pool.MaxBanTime) {
+				logs.Log.Errorf("[ban] %s total cool-down time exceeds %ds, give up", pool.BaseURL, pool.MaxBanTime)
+				ban.GaveUp = true
+				pool.recover()
+				pool.cancel()
+				pool.isFailed = true
+				break
+			}
+			logs.Log.Warnf("[ban] %s maybe banned, cool down %ds, reason: %s", pool.BaseURL, wait, ban.Reason)
+			select {
+			case <-time.After(time.Duration(wait) * time.Second):
+			case <-pool.ctx.Done():
+				ban.GaveUp = true
+			}
+			if ban.GaveUp {
+				break
+			}
+
+			ban.Probes++
+			if pool.reprobe() {
+				logs.Log.Importantf("[ban] %s recovered after %ds", pool.BaseURL, time.Now().Unix()-ban.Start)
+				pool.resetFailed()
+				break
+			}
+			wait *= 2
+		}
+
+		ban.End = time.Now().Unix()
+		pool.locker.Lock()
+		pool.Statistor.Bans = append(pool.Statistor.Bans, ban)
+		pool.locker.Unlock()
+		pool.banLocker.Lock()
+		close(pool.banCh)
+		pool.banCh = nil
+		pool.banLocker.Unlock()
+	}()
+}
+
+// probe 直接发送请求并返回结果, 不经过reqPool与后续的处理流程. 请求没有发出时返回nil
+func (pool *Pool) probe(unit *Unit) *pkg.Baseline {
+	unit.probe = make(chan *pkg.Baseline, 1)
+	pool.Invoke(unit)
+	select {
+	case bl := <-unit.probe:
+		return bl
+	default:
+		return nil
+	}
+}
+
+func (pool *Pool) probeCheck() *pkg.Baseline {
+	pool.Statistor.CheckNumber++
+	if pool.Mod == pkg.HostSpray {
+		return pool.probe(newUnit(pkg.RandHost(), CheckSource))
+	}
+	return pool.probe(newUnit(pool.safePath(pkg.RandPath()), CheckSource))
+}
+
+// reprobe 冷却结束后直接发送check请求, 不经过reqPool, 因为此时所有worker都被挂起
+func (pool *Pool) reprobe() bool {
+	bl := pool.probeCheck()
+	if bl == nil {
+		return false
+	}
+	if bl.ErrString != "" {
+		logs.Log.Debugf("[ban.probe] %s, error: %s", pool.BaseURL, bl.ErrString)
+		return false
+	}
+	logs.Log.Debug("[ban.probe] " + bl.String())
+	return pool.getRandom().Compare(bl) == 1
+}
+
+// doDrift check的结果与random baseline不一致时, 暂存之后的结果, 并立即发送多次check确认.
+// 如果连续MaxDrifts次得到一致的新页面, 说明404页面发生了变化, 重新校准并重新判断暂存的结果; 否则按原流程处理
+func (pool *Pool) doDrift(bl *pkg.Baseline) {
+	pool.driftLocker.Lock()
+	if pool.drifting {
+		pool.driftLocker.Unlock()
+		return
+	}
+	pool.drifting = true
+	pool.driftLocker.Unlock()
+
+	go func() {
+		bl.Collect()
+		drifts := []*pkg.Baseline{bl}
+		for i := 0; i < MaxDrifts+2 && len(drifts) < MaxDrifts; i++ {
+			check := pool.probeCheck()
+			if check == nil || check.ErrString != "" {
+				continue
+			}
+			check.Collect()
+			if pool.getRandom().Compare(check) == 1 {
+				// random baseline恢复一致, 只是偶发的异常
+				logs.Log.Debug("[drift.pass] " + check.String())
+				pool.release(false)
+				return
+			}
+			if similar(drifts[0], check) {
+				drifts = append(drifts, check)
+			} else {
+				drifts = []*pkg.Baseline{check}
+			}
+		}
+		pool.release(len(drifts) >= MaxDrifts && pool.rebaseline())
+	}()
+}
+
+func similar(base, other *pkg.Baseline) bool {
+	i := base.Compare(other)
+	return i == 1 || (i == 0 && base.FuzzyCompare(other))
+}
+
+// rebaseline 重新获取index与random baseline, 清空各状态码, 形态与目录的baseline, 之后按需重新校准
+func (pool *Pool) rebaseline() bool {
+	index := pool.probe(newUnit(pool.url.Path, InitIndexSource))
+	random := pool.probe(newUnit(pool.randomTarget(), InitRandomSource))
+	if index == nil || random == nil || index.ErrString != "" || random.ErrString != "" {
+		return false
+	}
+	index.Collect()
+	random.Collect()
+	logs.Log.Importantf("[rebaseline] %s 404 page changed, recalibrate, %s", pool.BaseURL, random.Format([]string{"status", "length", "title"}))
+	// 所有baseline在锁内整体替换, 对比时通过getIndex, getRandom与statusBaseline读取
+	pool.locker.Lock()
+	pool.index = index
+	pool.random = random
+	pool.baselines = make(map[int]*pkg.Baseline)
+	pool.shapes = make(map[string]*pkg.Baseline)
+	pool.shapeCount, pool.dirCount = 0, 0
+	pool.locker.Unlock()
+	pool.addFuzzyBaseline(random)
+	pool.Statistor.Rebaselines++
+	pool.resetFailed()
+	return true
+}
+
+// hold 确认404页面是否变化期间, 暂存所有结果
+func (pool *Pool) hold(bl *pkg.Baseline) bool {
+	pool.driftLocker.Lock()
+	defer pool.driftLocker.Unlock()
+	if !pool.drifting {
+		return false
+	}
+	pool.held = append(pool.held, bl)
+	return true
+}
+
+// release 结束暂存, 将暂存的结果重新送入处理流程. 如果已经重新校准, 预对比阶段被丢弃的请求使用新的baseline重新发送
+func (pool *Pool) release(rebaselined bool) {
+	pool.driftLocker.Lock()
+	held := pool.held
+	pool.held = nil
+	pool.drifting = false
+	pool.driftLocker.Unlock()
+
+	for _, bl := range held {
+		if rebaselined && !bl.IsValid && bl.ErrString == "" && bl.Body == nil {
+			pool.addAddition(&Unit{
+				path:   strings.TrimPrefix(bl.UrlString, pool.base),
+				source: RetrySource,
+				number: bl.Number,
+				word:   bl.Source == WordSource,
+			})
+			pool.waiter.Done()
+			continue
+		}
+		pool.tempCh <- bl
+	}
+}
+
 func (pool *Pool) addAddition(u *Unit) {
 	// 强行屏蔽报错, 防止goroutine泄露
 	pool.waiter.Add(1)
@@ -793,17 +2079,110 @@ func (pool *Pool) addAddition(u *Unit) {
 		if err := recover(); err != nil {
 		}
 	}()
-	pool.additionCh <- u
+	select {
+	case pool.additionCh <- u:
+	case <-pool.ctx.Done():
+		// 任务已被取消, 保存到stat中用于断点续传
+		pool.savePending(u)
+		pool.waiter.Done()
+	}
+}
+
+func (pool *Pool) savePending(u *Unit) {
+	pool.locker.Lock()
+	defer pool.locker.Unlock()
+	pool.Statistor.Additions = append(pool.Statistor.Additions, &pkg.Addition{
+		Path:   u.path,
+		Source: u.source,
+		Depth:  u.depth,
+		Retry:  u.retry,
+		From:   u.from,
+		API:    u.api,
+	})
+}
+
+// savePendingAdditions 持续消费addition管道直到waiter归零, 已经计入waiter的任务在保存后同样需要Done
+func (pool *Pool) savePendingAdditions() {
+	finished := make(chan struct{})
+	go func() {
+		pool.waiter.Wait()
+		close(finished)
+	}()
+	for {
+		select {
+		case u := <-pool.additionCh:
+			pool.savePending(u)
+			pool.waiter.Done()
+		case <-finished:
+			return
+		}
+	}
+}
+
+// statJson 在锁中序列化stat, 避免与仍在运行的插件同时读写
+func (pool *Pool) statJson() string {
+	pool.locker.Lock()
+	defer pool.locker.Unlock()
+	return pool.Statistor.Json()
+}
+
+func (pool *Pool) doResume(additions []*pkg.Addition) {
+	defer pool.waiter.Done()
+	for _, a := range additions {
+		if a.API != nil && a.API.Unsafe && !pool.APIUnsafe {
+			// 上次运行指定了--api-unsafe, 本次未指定时不再发送
+			continue
+		}
+		pool.addAddition(&Unit{
+			path:   a.Path,
+			source: a.Source,
+			depth:  a.Depth,
+			retry:  a.Retry,
+			from:   a.From,
+			api:    a.API,
+		})
+	}
 }
 
 func (pool *Pool) addFuzzyBaseline(bl *pkg.Baseline) {
-	if _, ok := pool.baselines[bl.Status]; !ok && (enableAllFuzzy || iutils.IntsContains(FuzzyStatus, bl.Status)) {
-		bl.Collect()
-		pool.waiter.Add(1)
-		pool.doCrawl(bl) // 非有效页面也可能存在一些特殊的url可以用来爬取
-		pool.baselines[bl.Status] = bl
-		logs.Log.Infof("[baseline.%dinit] %s", bl.Status, bl.Format([]string{"status", "length", "spend", "title", "frame", "redirect"}))
+	if !enableAllFuzzy && !iutils.IntsContains(FuzzyStatus, bl.Status) {
+		return
+	}
+	if _, ok := pool.statusBaseline(bl.Status); ok {
+		return
 	}
+	// 先收集完再发布, 避免对比时读取到收集中的baseline
+	bl.Collect()
+	pool.locker.Lock()
+	if _, ok := pool.baselines[bl.Status]; ok {
+		pool.locker.Unlock()
+		return
+	}
+	pool.baselines[bl.Status] = bl
+	pool.locker.Unlock()
+	pool.waiter.Add(1)
+	pool.doCrawl(bl) // 非有效页面也可能存在一些特殊的url可以用来爬取
+	logs.Log.Infof("[baseline.%dinit] %s", bl.Status, bl.Format([]string{"status", "length", "spend", "title", "frame", "redirect"}))
+}
+
+// getIndex, getRandom与statusBaseline 运行期间rebaseline会在其他goroutine中替换baseline, 需要加锁读取
+func (pool *Pool) getIndex() *pkg.Baseline {
+	pool.locker.Lock()
+	defer pool.locker.Unlock()
+	return pool.index
+}
+
+func (pool *Pool) getRandom() *pkg.Baseline {
+	pool.locker.Lock()
+	defer pool.locker.Unlock()
+	return pool.random
+}
+
+func (pool *Pool) statusBaseline(status int) (*pkg.Baseline, bool) {
+	pool.locker.Lock()
+	defer pool.locker.Unlock()
+	bl, ok := pool.baselines[status]
+	return bl, ok
 }
 
 func (pool *Pool) putToInvalid(bl *pkg.Baseline, reason string) {
@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"context"
+	"github.com/chainreactors/spray/pkg"
+	"github.com/chainreactors/spray/pkg/ihttp"
+	"github.com/valyala/fasthttp"
+	"testing"
+	"time"
+)
+
+// testBaseline 不发送请求, 直接根据状态码, header与body生成baseline
+func testBaseline(u string, status int, header map[string]string, body string) *pkg.Baseline {
+	resp := &fasthttp.Response{}
+	resp.SetStatusCode(status)
+	resp.Header.SetContentType("text/html")
+	for k, v := range header {
+		resp.Header.Set(k, v)
+	}
+	resp.SetBodyString(body)
+	resp.Header.SetContentLength(len(body))
+	return pkg.NewBaseline(u, "127.0.0.1", &ihttp.Response{FastResponse: resp, ClientType: ihttp.FAST})
+}
+
+// newTestPool 创建只运行Handler的pool, index与random由调用者指定, 不发送任何请求
+func newTestPool(t *testing.T, config *pkg.Config, index, random *pkg.Baseline) *Pool {
+	config.BaseURL = "http://127.0.0.1/"
+	config.Thread = 1
+	config.Mod = pkg.PathSpray
+	config.OutputCh = make(chan *pkg.Baseline, 100)
+	config.FuzzyCh = make(chan *pkg.Baseline, 100)
+	pool, err := NewPool(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Statistor = pkg.NewStatistor(config.BaseURL)
+	index.Collect()
+	random.Collect()
+	pool.index, pool.random = index, random
+	t.Cleanup(func() {
+		pool.cancel()
+		close(pool.tempCh)
+	})
+	return pool
+}
+
+// handle 将结果送入Handler, 返回输出的结果
+func handle(t *testing.T, pool *Pool, bl *pkg.Baseline) *pkg.Baseline {
+	pool.waiter.Add(1)
+	pool.tempCh <- bl
+	select {
+	case out := <-pool.OutputCh:
+		return out
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler did not output the result")
+	}
+	return nil
+}
+
+func TestHandlerGlobalWafHeader(t *testing.T) {
+	cf := map[string]string{"Server": "cloudflare", "CF-RAY": "7d1c2e3f4a5b6c7d-HKG"}
+	index := testBaseline("http://127.0.0.1/", 200, cf, "<html>home</html>")
+	random := testBaseline("http://127.0.0.1/nonexist", 404, cf, "not found")
+	pool := newTestPool(t, &pkg.Config{}, index, random)
+
+	// 目标整体位于cloudflare之后, 真实的405不是拦截页面
+	bl := handle(t, pool, testBaseline("http://127.0.0.1/api/upload", 405, cf, "method not allowed"))
+	if !bl.IsValid || bl.Waf != "" {
+		t.Errorf("hit behind global waf header not reported: valid=%v waf=%s reason=%s", bl.IsValid, bl.Waf, bl.Reason)
+	}
+	if pool.Statistor.WafedNumber != 0 || pool.Statistor.FoundNumber != 1 {
+		t.Errorf("wafed = %d, found = %d", pool.Statistor.WafedNumber, pool.Statistor.FoundNumber)
+	}
+
+	// 拦截页面的特征仍然生效
+	bl = handle(t, pool, testBaseline("http://127.0.0.1/admin", 403, cf, "<title>Attention Required! | Cloudflare</title>"))
+	if bl.IsValid || bl.Waf != "cloudflare" {
+		t.Errorf("block page not marked: valid=%v waf=%s reason=%s", bl.IsValid, bl.Waf, bl.Reason)
+	}
+}
+
+func TestHandlerWafHeaderOnlyOnHit(t *testing.T) {
+	index := testBaseline("http://127.0.0.1/", 200, nil, "<html>home</html>")
+	random := testBaseline("http://127.0.0.1/nonexist", 404, nil, "not found")
+	pool := newTestPool(t, &pkg.Config{}, index, random)
+
+	bl := handle(t, pool, testBaseline("http://127.0.0.1/api/upload", 405, map[string]string{"X-Sucuri-Block": "1"}, "denied"))
+	if bl.IsValid || bl.Waf != "sucuri" || bl.Reason != pkg.ErrWaf.Error() {
+		t.Errorf("waf header absent from baselines not marked: valid=%v waf=%s reason=%s", bl.IsValid, bl.Waf, bl.Reason)
+	}
+	if pool.Statistor.WafedNumber != 1 {
+		t.Errorf("wafed = %d, want 1", pool.Statistor.WafedNumber)
+	}
+}
+
+// drain 读取当前已经输出的结果
+func drain(ch chan *pkg.Baseline) []*pkg.Baseline {
+	var bls []*pkg.Baseline
+	for {
+		select {
+		case bl := <-ch:
+			bls = append(bls, bl)
+		case <-time.After(200 * time.Millisecond):
+			return bls
+		}
+	}
+}
+
+func TestHandlerClusterHold(t *testing.T) {
+	index := testBaseline("http://127.0.0.1/", 200, nil, "<html>home</html>")
+	random := testBaseline("http://127.0.0.1/nonexist", 404, nil, "not found")
+	pool := newTestPool(t, &pkg.Config{ClusterRatio: 0.5, ClusterMin: 3}, index, random)
+
+	// 类的数量达到cluster-min之前暂存, 不输出
+	for _, p := range []string{"a", "b"} {
+		pool.waiter.Add(1)
+		pool.tempCh <- testBaseline("http://127.0.0.1/"+p, 405, nil, "<html>wildcard page</html>")
+	}
+	if bls := drain(pool.OutputCh); len(bls) != 0 {
+		t.Fatalf("cluster members output before the cluster was decided: %d", len(bls))
+	}
+
+	// 第三个成员使类被标记, 暂存的成员与当前结果都作为soft-404输出
+	pool.waiter.Add(1)
+	pool.tempCh <- testBaseline("http://127.0.0.1/c", 405, nil, "<html>wildcard page</html>")
+	outputs, fuzzy := drain(pool.OutputCh), drain(pool.FuzzyCh)
+	if len(outputs) != 3 || len(fuzzy) != 3 {
+		t.Fatalf("outputs = %d, fuzzy = %d, want 3", len(outputs), len(fuzzy))
+	}
+	for _, bl := range append(outputs, fuzzy...) {
+		if bl.IsValid || bl.Reason != pkg.ErrFuzzyCluster.Error() {
+			t.Errorf("%s: valid=%v reason=%s", bl.UrlString, bl.IsValid, bl.Reason)
+		}
+	}
+	if pool.Statistor.FoundNumber != 0 || pool.Statistor.FuzzyNumber != 3 {
+		t.Errorf("found = %d, fuzzy = %d", pool.Statistor.FoundNumber, pool.Statistor.FuzzyNumber)
+	}
+
+	// 已经标记的类的新成员直接作为soft-404
+	pool.waiter.Add(1)
+	pool.tempCh <- testBaseline("http://127.0.0.1/d", 405, nil, "<html>wildcard page</html>")
+	if bls := drain(pool.OutputCh); len(bls) != 1 || bls[0].IsValid {
+		t.Errorf("member of marked cluster reported as valid")
+	}
+	drain(pool.FuzzyCh)
+
+	// 任务结束时仍未确定的类, 暂存的成员作为有效结果输出
+	pool.waiter.Add(1)
+	pool.tempCh <- testBaseline("http://127.0.0.1/admin", 401, nil, "<html><title>admin</title>login required</html>")
+	if bls := drain(pool.OutputCh); len(bls) != 0 {
+		t.Fatalf("undecided member output before release")
+	}
+	pool.waiter.Add(1)
+	pool.tempCh <- nil
+	bls := drain(pool.OutputCh)
+	if len(bls) != 1 || !bls[0].IsValid || bls[0].UrlString != "http://127.0.0.1/admin" {
+		t.Fatalf("released %d results", len(bls))
+	}
+	if pool.Statistor.FoundNumber != 1 {
+		t.Errorf("found = %d, want 1", pool.Statistor.FoundNumber)
+	}
+	if fuzzy := drain(pool.FuzzyCh); len(fuzzy) != 0 {
+		t.Errorf("released member also reported as fuzzy")
+	}
+}
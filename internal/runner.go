@@ -3,6 +3,7 @@ package internal
 import (
 	"context"
 	"fmt"
+	"github.com/antonmedv/expr"
 	"github.com/antonmedv/expr/vm"
 	"github.com/chainreactors/files"
 	"github.com/chainreactors/logs"
@@ -12,6 +13,7 @@ import (
 	"github.com/chainreactors/words/rule"
 	"github.com/gosuri/uiprogress"
 	"github.com/panjf2000/ants/v2"
+	"strings"
 	"sync"
 	"time"
 )
@@ -26,7 +28,9 @@ var (
 
 var (
 	dictCache     = make(map[string][]string)
+	dictLocker    sync.Mutex
 	wordlistCache = make(map[string][]string)
+	cacheLocker   sync.Mutex
 	ruleCache     = make(map[string][]rule.Expression)
 )
 
@@ -35,10 +39,12 @@ type Runner struct {
 	poolwg   sync.WaitGroup
 	bar      *uiprogress.Bar
 	finished int
+	stopAt   time.Time // 优雅退出的时间点, 在deadline之前停止分发新的任务
 
 	Tasks           chan *Task
 	Count           int // tasks total number
 	Wordlist        []string
+	Stream          *wordStream
 	Rules           *rule.Program
 	AppendRules     *rule.Program
 	Headers         map[string]string
@@ -47,11 +53,16 @@ type Runner struct {
 	MatchExpr       *vm.Program
 	RecursiveExpr   *vm.Program
 	RecuDepth       int
+	Policies        []*Policy
 	Threads         int
 	PoolSize        int
 	ClientType      int
 	Pools           *ants.PoolWithFunc
-	PoolName        map[string]bool
+	DirTree         *pkg.DirTree
+	Harvester       *pkg.Harvester
+	HarvestFile     *files.File
+	AutoExt         bool
+	TreeFile        *files.File
 	Timeout         int
 	Mod             string
 	Probes          []string
@@ -68,6 +79,9 @@ type Runner struct {
 	RateLimit       int
 	Total           int // wordlist total number
 	Deadline        int
+	DeadlineGrace   int
+	TargetTime      int
+	TargetRequests  int
 	CheckPeriod     int
 	ErrPeriod       int
 	BreakThreshold  int
@@ -75,13 +89,31 @@ type Runner struct {
 	CheckOnly       bool
 	Force           bool
 	IgnoreWaf       bool
+	WafProbe        bool
 	Crawl           bool
 	Scope           []string
 	Active          bool
 	Bak             bool
 	Common          bool
+	Pack            bool
+	Leak            bool
+	Seed            bool
+	JsAnalyze       bool
+	API             bool
+	APIUnsafe       bool
 	RetryCount      int
+	RetryPolicy     map[string]int
+	RetryBackoff    int
 	RandomUserAgent bool
+	Dedup           *pkg.Deduplicator
+	Mirror          string
+	MirrorSample    int
+	Mirrors         *pkg.Mirrors
+	Shapes          []string
+	ClusterRatio    float64
+	ClusterMin      int
+	CoolDown        int
+	MaxBanTime      int
 }
 
 func (r *Runner) PrepareConfig() *pkg.Config {
@@ -102,14 +134,33 @@ func (r *Runner) PrepareConfig() *pkg.Config {
 		RecuExpr:        r.RecursiveExpr,
 		AppendRule:      r.AppendRules,
 		IgnoreWaf:       r.IgnoreWaf,
+		WafProbe:        r.WafProbe,
 		Crawl:           r.Crawl,
 		Scope:           r.Scope,
 		Active:          r.Active,
 		Bak:             r.Bak,
 		Common:          r.Common,
+		Pack:            r.Pack,
+		Leak:            r.Leak,
+		Seed:            r.Seed,
+		JsAnalyze:       r.JsAnalyze,
+		API:             r.API,
+		APIUnsafe:       r.APIUnsafe,
 		Retry:           r.RetryCount,
+		RetryPolicy:     r.RetryPolicy,
+		RetryBackoff:    r.RetryBackoff,
 		ClientType:      r.ClientType,
 		RandomUserAgent: r.RandomUserAgent,
+		Dedup:           r.Dedup,
+		Harvester:       r.Harvester,
+		Shapes:          r.Shapes,
+		ClusterRatio:    r.ClusterRatio,
+		ClusterMin:      r.ClusterMin,
+		CoolDown:        r.CoolDown,
+		MaxBanTime:      r.MaxBanTime,
+		Deadline:        r.stopAt,
+		TargetTime:      r.TargetTime,
+		TargetRequests:  r.TargetRequests,
 	}
 
 	if config.ClientType == ihttp.Auto {
@@ -124,6 +175,10 @@ func (r *Runner) PrepareConfig() *pkg.Config {
 
 func (r *Runner) Prepare(ctx context.Context) error {
 	var err error
+	if r.Deadline > 0 {
+		// 在deadline之前预留grace的时间, 用于处理已经发出的请求与保存stat
+		r.stopAt = time.Now().Add(time.Duration(r.Deadline-r.DeadlineGrace) * time.Second)
+	}
 	if r.CheckOnly {
 		// 仅check, 类似httpx
 		r.Pools, err = ants.NewPoolWithFunc(1, func(i interface{}) {
@@ -170,13 +225,25 @@ func (r *Runner) Prepare(ctx context.Context) error {
 
 		r.Pools, err = ants.NewPoolWithFunc(r.PoolSize, func(i interface{}) {
 			t := i.(*Task)
-			if t.origin != nil && t.origin.End == t.origin.Total {
+			if t.origin != nil && t.origin.Finished() {
 				r.StatFile.SafeWrite(t.origin.Json())
 				r.Done()
 				return
 			}
+			if r.expired() {
+				// 临近deadline, 不再启动新的任务, 保存到stat中用于断点续传
+				r.saveUnstarted(t)
+				r.Done()
+				return
+			}
 			config := r.PrepareConfig()
 			config.BaseURL = t.baseUrl
+			if t.policy != nil {
+				config.MaxRecursion = t.policy.MaxDepth
+			} else if t.origin != nil && t.origin.Policy != "" && t.origin.Generator != nil {
+				// 断点续传的递归策略任务, 策略的递归深度已经记录在stat的生成器配置中
+				config.MaxRecursion = t.origin.Generator.MaxDepth
+			}
 
 			pool, err := NewPool(ctx, config)
 			if err != nil {
@@ -185,21 +252,47 @@ func (r *Runner) Prepare(ctx context.Context) error {
 				r.Done()
 				return
 			}
-			if t.origin != nil && len(r.Wordlist) == 0 {
-				// 如果是从断点续传中恢复的任务, 则自动设置word,dict与rule, 不过优先级低于命令行参数
+			// 自动后缀只作用于命令行中指定的字典
+			autoExt := r.AutoExt && config.Mod == pkg.PathSpray && r.Stream == nil && t.policy == nil
+			if t.policy != nil {
+				// 递归任务命中了递归策略, 使用策略中的字典
+				pool.Statistor = pkg.NewStatistor(t.baseUrl)
+				err = r.initPolicy(pool, t.policy)
+				if err != nil {
+					logs.Log.Error(err.Error())
+					pool.cancel()
+					r.Done()
+					return
+				}
+			} else if t.origin != nil && (!r.hasWordlist() || t.origin.Policy != "") {
+				// 如果是从断点续传中恢复的任务, 则自动设置word,dict与rule, 不过优先级低于命令行参数. 使用了递归策略的任务始终使用stat中的字典
+				autoExt = false
 				pool.Statistor = pkg.NewStatistorFromStat(t.origin.Statistor)
-				pool.worder, err = t.origin.InitWorder(r.Fns)
+				if t.origin.IsStream() {
+					pool.stream, err = t.origin.InitStream(r.Fns)
+				} else {
+					pool.worder, err = t.origin.InitWorder(r.Fns)
+				}
 				if err != nil {
 					logs.Log.Error(err.Error())
+					pool.cancel()
 					r.Done()
 					return
 				}
 				pool.Statistor.Total = t.origin.sum
+			} else if t.origin != nil {
+				// 命令行指定了字典, 只继承stat中的递归与插件状态
+				pool.Statistor = pkg.NewStatistorFromStat(t.origin.Statistor)
+				pool.Statistor.Word = pkg.DefaultStatistor.Word
+				pool.Statistor.Dictionaries = pkg.DefaultStatistor.Dictionaries
+				pool.Statistor.RuleFiles = pkg.DefaultStatistor.RuleFiles
+				pool.Statistor.RuleFilter = pkg.DefaultStatistor.RuleFilter
+				pool.Statistor.Generator = pkg.DefaultStatistor.Generator
+				pool.Statistor.Total = r.Total
+				r.initWorder(pool)
 			} else {
 				pool.Statistor = pkg.NewStatistor(t.baseUrl)
-				pool.worder = words.NewWorder(r.Wordlist)
-				pool.worder.Fns = r.Fns
-				pool.worder.Rules = r.Rules.Expressions
+				r.initWorder(pool)
 			}
 
 			var limit int
@@ -208,12 +301,13 @@ func (r *Runner) Prepare(ctx context.Context) error {
 			} else {
 				limit = pool.Statistor.Total
 			}
-			pool.bar = pkg.NewBar(config.BaseURL, limit-pool.Statistor.Offset, r.Progress)
+			pool.bar = pkg.NewBar(config.BaseURL, limit-pool.Statistor.Offset-pool.Statistor.Completed.Count(), r.Progress)
 			err = pool.Init()
 			if err != nil {
 				pool.Statistor.Error = err.Error()
 				if !r.Force {
 					// 如果没开启force, init失败将会关闭pool
+					pool.cancel()
 					pool.Close()
 					r.PrintStat(pool)
 					r.Done()
@@ -221,11 +315,31 @@ func (r *Runner) Prepare(ctx context.Context) error {
 				}
 			}
 
-			pool.Run(pool.Statistor.Offset, limit)
+			if err == nil && autoExt {
+				r.autoExtensions(pool, &limit)
+			}
 
-			if pool.isFailed && len(pool.failedBaselines) > 0 {
-				// 如果因为错误积累退出, end将指向第一个错误发生时, 防止resume时跳过大量目标
-				pool.Statistor.End = pool.failedBaselines[0].Number
+			if err == nil && t.depth == 0 && r.Mirrors != nil && !r.checkMirror(pool, &limit) {
+				// 没有执行Run, 需要手动结束Init中启动的goroutine
+				pool.cancel()
+				pool.Close()
+				r.PrintStat(pool)
+				r.Done()
+				return
+			}
+
+			pool.Statistor.Depth = t.depth
+			if t.origin != nil && len(t.origin.Additions) > 0 {
+				// 恢复上次中断时未处理的插件任务
+				pool.waiter.Add(1)
+				go pool.doResume(t.origin.Additions)
+			}
+			pool.Run(pool.Statistor.Offset, limit)
+			if pool.mirror != nil {
+				// 将结果同步给等待中的镜像目标
+				for _, bls := range pool.mirror.Finish() {
+					r.outputMirror(bls)
+				}
 			}
 			r.PrintStat(pool)
 			r.Done()
@@ -239,6 +353,134 @@ func (r *Runner) Prepare(ctx context.Context) error {
 	return nil
 }
 
+// checkMirror 与已经初始化的目标对比index与random baseline, 返回false表示该目标不再需要喷洒
+func (r *Runner) checkMirror(pool *Pool, limit *int) bool {
+	m, ok := r.Mirrors.Match(pool.BaseURL, pool.base, pool.index, pool.random)
+	if !ok {
+		if r.Mirror == pkg.MirrorReuse {
+			pool.mirror = m
+		}
+		return true
+	}
+
+	pool.Statistor.MirrorOf = m.Primary
+	logs.Log.Importantf("[mirror] %s is mirror of %s, %s", pool.BaseURL, m.Primary, r.Mirror)
+	switch r.Mirror {
+	case pkg.MirrorSample:
+		if sample := pool.Statistor.Offset + r.MirrorSample; sample < *limit {
+			*limit = sample
+		}
+		return true
+	case pkg.MirrorReuse:
+		if bls, ok := m.Reuse(pool.BaseURL); ok {
+			r.outputMirror(bls)
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+func (r *Runner) outputMirror(bls []*pkg.Baseline) {
+	for _, bl := range bls {
+		r.OutputCh <- bl
+	}
+}
+
+// autoExtensions 根据目标的技术栈为字典追加后缀, 选择的后缀与依据记录到stat中, 断点续传时直接使用
+func (r *Runner) autoExtensions(pool *Pool, limit *int) {
+	exts, evidences := pool.detectExtensions()
+	pool.Statistor.AutoExtensions = exts
+	pool.Statistor.ExtensionEvidences = evidences
+	if len(exts) == 0 {
+		logs.Log.Importantf("[auto-ext] %s no extension detected", pool.BaseURL)
+		return
+	}
+	logs.Log.Importantf("[auto-ext] %s use %s, %s", pool.BaseURL, strings.Join(exts, ","), strings.Join(evidences, "; "))
+
+	wl := pkg.ExpandExtensions(r.Wordlist, exts)
+	pool.worder = words.NewWorder(wl)
+	pool.worder.Fns = r.Fns
+	pool.worder.Rules = r.Rules.Expressions
+	pool.Statistor.Total = len(wl)
+	if len(r.Rules.Expressions) > 0 {
+		pool.Statistor.Total *= len(r.Rules.Expressions)
+	}
+	if pool.Statistor.Total > r.Limit && r.Limit != 0 {
+		*limit = r.Limit
+	} else {
+		*limit = pool.Statistor.Total
+	}
+	pool.bar.Total = *limit - pool.Statistor.Offset - pool.Statistor.Completed.Count()
+}
+
+// expired 是否已经到达优雅退出的时间点
+func (r *Runner) expired() bool {
+	return !r.stopAt.IsZero() && time.Now().After(r.stopAt)
+}
+
+func (r *Runner) saveUnstarted(t *Task) {
+	if r.StatFile == nil {
+		return
+	}
+	if t.origin != nil {
+		r.StatFile.SafeWrite(t.origin.Json())
+	} else {
+		stat := pkg.NewStatistor(t.baseUrl)
+		stat.Depth = t.depth
+		r.StatFile.SafeWrite(stat.Json())
+	}
+	r.StatFile.SafeSync()
+}
+
+// initPolicy 使用递归策略生成字典, 并将策略展开后的字典配置记录到stat中, 断点续传时可以直接还原
+func (r *Runner) initPolicy(pool *Pool, policy *Policy) error {
+	worder, count, err := policy.Worder(r.Fns)
+	if err != nil {
+		return err
+	}
+	pool.worder = worder
+
+	stat := pool.Statistor
+	stat.Policy = policy.Name
+	stat.Offset = 0
+	stat.Word = policy.word()
+	stat.WordCount = count
+	stat.Dictionaries = policy.Dictionaries
+	stat.RuleFiles = policy.Rules
+	stat.RuleFilter = ""
+	stat.Total = count
+	if len(policy.rules) > 0 {
+		stat.Total = count * len(policy.rules)
+	}
+	var gen pkg.Generator
+	if stat.Generator != nil {
+		gen = *stat.Generator
+	}
+	gen.Extensions = policy.Extensions
+	gen.Stream = false
+	if policy.MaxDepth != 0 {
+		gen.MaxDepth = policy.MaxDepth
+	}
+	stat.Generator = &gen
+	return nil
+}
+
+// hasWordlist 命令行中是否指定了字典
+func (r *Runner) hasWordlist() bool {
+	return len(r.Wordlist) > 0 || r.Stream != nil
+}
+
+func (r *Runner) initWorder(pool *Pool) {
+	if r.Stream != nil {
+		pool.stream = r.Stream
+		return
+	}
+	pool.worder = words.NewWorder(r.Wordlist)
+	pool.worder.Fns = r.Fns
+	pool.worder.Rules = r.Rules.Expressions
+}
+
 func (r *Runner) AddRecursive(bl *pkg.Baseline) {
 	// 递归新任务
 	task := &Task{
@@ -246,21 +488,100 @@ func (r *Runner) AddRecursive(bl *pkg.Baseline) {
 		depth:   bl.RecuDepth + 1,
 		origin:  NewOrigin(pkg.NewStatistor(bl.UrlString)),
 	}
+	if policy := MatchPolicy(r.Policies, bl.Path, task.depth); policy != nil {
+		task.policy = policy
+		logs.Log.Importantf("[policy] %s depth %d, use recursive policy %s", bl.UrlString, task.depth, policy.Name)
+	}
 
-	r.AddPool(task)
+	// 不同pool通过爬虫, 字典等方式可能发现同一个目录, 统一在目录树中去重
+	if err := r.DirTree.Add(task.baseUrl, bl); err != nil {
+		logs.Log.Debugf("[recursion] skip %s, %s", task.baseUrl, err.Error())
+		return
+	}
+	r.poolwg.Add(1)
+	r.Pools.Invoke(task)
 }
 
 func (r *Runner) AddPool(task *Task) {
-	// 递归新任务
-	if _, ok := r.PoolName[task.baseUrl]; ok {
+	if err := r.DirTree.Add(task.baseUrl, nil); err != nil {
 		logs.Log.Importantf("already added pool, skip %s", task.baseUrl)
 		return
 	}
-	task.depth++
 	r.poolwg.Add(1)
 	r.Pools.Invoke(task)
 }
 
+// restoreFromStat 断点续传时, 还原命令行中未指定的过滤, 递归与append rule配置, 优先级低于命令行参数
+func (r *Runner) restoreFromStat(stat *pkg.Statistor) error {
+	gen := stat.Generator
+	if gen == nil {
+		return nil
+	}
+
+	if r.MatchExpr == nil && gen.MatchExpr != "" {
+		exp, err := expr.Compile(gen.MatchExpr)
+		if err != nil {
+			return err
+		}
+		r.MatchExpr = exp
+	}
+
+	if r.FilterExpr == nil && gen.FilterExpr != "" {
+		exp, err := expr.Compile(gen.FilterExpr)
+		if err != nil {
+			return err
+		}
+		r.FilterExpr = exp
+	}
+
+	if r.RecursiveExpr == nil && gen.RecuExpr != "" {
+		exp, err := expr.Compile(gen.RecuExpr)
+		if err != nil {
+			return err
+		}
+		r.RecursiveExpr = exp
+		if MaxRecursion == 0 {
+			MaxRecursion = gen.MaxDepth
+		}
+	}
+
+	if r.AppendRules == nil && len(gen.AppendRules) > 0 {
+		content, err := loadFileAndCombine(gen.AppendRules)
+		if err != nil {
+			return err
+		}
+		r.AppendRules = rule.Compile(content, "")
+	}
+
+	if !r.hasWordlist() {
+		// 命令行没有指定字典时, 新生成的递归任务继承stat中的字典与生成器配置
+		current := pkg.DefaultStatistor.Generator
+		restored := *gen
+		if current != nil {
+			if current.MatchExpr != "" {
+				restored.MatchExpr = current.MatchExpr
+			}
+			if current.FilterExpr != "" {
+				restored.FilterExpr = current.FilterExpr
+			}
+			if current.RecuExpr != "" {
+				restored.RecuExpr = current.RecuExpr
+				restored.MaxDepth = current.MaxDepth
+			}
+			if len(current.AppendRules) > 0 {
+				restored.AppendRules = current.AppendRules
+			}
+		}
+		pkg.DefaultStatistor.Word = stat.Word
+		pkg.DefaultStatistor.Dictionaries = stat.Dictionaries
+		pkg.DefaultStatistor.RuleFiles = stat.RuleFiles
+		pkg.DefaultStatistor.RuleFilter = stat.RuleFilter
+		pkg.DefaultStatistor.Total = stat.Total
+		pkg.DefaultStatistor.Generator = &restored
+	}
+	return nil
+}
+
 func (r *Runner) Run(ctx context.Context) {
 Loop:
 	for {
@@ -296,6 +617,46 @@ Loop:
 		}
 	}
 	time.Sleep(100 * time.Millisecond) // 延迟100ms, 等所有数据处理完毕
+	r.PrintDedup()
+	r.PrintTree()
+	r.PrintHarvest()
+}
+
+// PrintHarvest 输出从响应中收集到的单词, 可以作为下一次的字典
+func (r *Runner) PrintHarvest() {
+	if r.Harvester == nil {
+		return
+	}
+	harvests := r.Harvester.Words()
+	logs.Log.Importantf("[harvest] %d words harvested", len(harvests))
+	if r.HarvestFile != nil && len(harvests) > 0 {
+		r.HarvestFile.SafeWrite(strings.Join(harvests, "\n") + "\n")
+		r.HarvestFile.SafeSync()
+	}
+}
+
+// PrintTree 输出递归发现的目录树
+func (r *Runner) PrintTree() {
+	if r.DirTree.Recursions() == 0 {
+		return
+	}
+	tree := r.DirTree.String()
+	logs.Log.Importantf("[recursion] %d directories recursed\n%s", r.DirTree.Recursions(), tree)
+	if r.TreeFile != nil {
+		r.TreeFile.SafeWrite(tree)
+		r.TreeFile.SafeSync()
+	}
+}
+
+func (r *Runner) PrintDedup() {
+	if r.Dedup == nil {
+		return
+	}
+	if r.Dedup.IsBloom() {
+		logs.Log.Importantf("[dedup] %d urls deduplicated by bloom filter, estimated false positive rate: %.6f", r.Dedup.Count(), r.Dedup.FalsePositiveRate())
+	} else {
+		logs.Log.Importantf("[dedup] %d urls deduplicated by exact set", r.Dedup.Count())
+	}
 }
 
 func (r *Runner) RunWithCheck(ctx context.Context) {
@@ -351,8 +712,12 @@ func (r *Runner) PrintStat(pool *Pool) {
 		}
 	}
 
+	for _, v := range pool.Statistor.Vhosts {
+		logs.Log.Importantf("[vhost] %s -> %s", pool.addr, v.String())
+	}
+
 	if r.StatFile != nil {
-		r.StatFile.SafeWrite(pool.Statistor.Json())
+		r.StatFile.SafeWrite(pool.statJson())
 		r.StatFile.SafeSync()
 	}
 }
@@ -0,0 +1,254 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/chainreactors/words"
+	"github.com/chainreactors/words/mask"
+	"github.com/chainreactors/words/rule"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// 字典的行数, 断点续传时从stat中还原, 避免重复扫描大字典. 多个任务并发读写
+	lineCountCache  = make(map[string]int)
+	lineCountLocker sync.Mutex
+)
+
+// newWordStream 解析word dsl, 字典占位符({?0}, {?01})对应的文件按行流式读取,
+// 其他占位符({@ext}, {?ld#4}等)只在单个占位符内展开. 内存占用为各占位符之和, 而不是笛卡尔积.
+// keywords为nil时使用全局的mask.SpecialWords
+func newWordStream(word string, dictNames []string, keywords map[string][]string) (*wordStream, error) {
+	stream := &wordStream{word: word, Count: 1}
+	for len(word) > 0 {
+		i := strings.Index(word, "{")
+		if i == -1 {
+			stream.addLiteral(word)
+			break
+		}
+		if i > 0 {
+			stream.addLiteral(word[:i])
+		}
+		j := strings.Index(word[i:], "}")
+		if j == -1 {
+			return nil, fmt.Errorf("%s: unclosed mask", stream.word)
+		}
+		token, err := newStreamToken(word[i:i+j+1], dictNames, keywords)
+		if err != nil {
+			return nil, err
+		}
+		stream.tokens = append(stream.tokens, token)
+		stream.Count *= token.count
+		word = word[i+j+1:]
+	}
+	return stream, nil
+}
+
+type wordStream struct {
+	word   string
+	tokens []*streamToken
+	Count  int // 不包含rule的word总数
+	Rules  []rule.Expression
+	Fns    []func(string) string
+}
+
+func (stream *wordStream) addLiteral(s string) {
+	stream.tokens = append(stream.tokens, &streamToken{words: []string{s}, count: 1})
+}
+
+// Worder 每次调用生成一个新的worder, 所有的展开都在读取时进行. 调用后需要执行worder.Run
+// ctx结束后停止读取字典, 防止任务中断后goroutine与文件句柄泄露
+func (stream *wordStream) Worder(ctx context.Context) *words.Worder {
+	return words.NewWorderWithChan(stream.generate(ctx))
+}
+
+// generate 在goroutine中展开word, ctx结束或全部展开后关闭管道
+func (stream *wordStream) generate(ctx context.Context) chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		g := &streamGenerator{wordStream: stream, ctx: ctx, ch: ch}
+		g.walk(0, "")
+	}()
+	return ch
+}
+
+type streamGenerator struct {
+	*wordStream
+	ctx     context.Context
+	ch      chan string
+	stopped bool
+}
+
+func (g *streamGenerator) walk(i int, prefix string) {
+	if g.stopped {
+		return
+	}
+	if i == len(g.tokens) {
+		g.output(prefix)
+		return
+	}
+
+	token := g.tokens[i]
+	if token.files == nil {
+		for _, w := range token.words {
+			g.walk(i+1, prefix+w)
+		}
+		return
+	}
+
+	for _, filename := range token.files {
+		err := readLines(filename, func(line string) bool {
+			g.walk(i+1, prefix+line)
+			return !g.stopped
+		})
+		if err != nil {
+			// 字典在开始前已经统计过行数, 这里失败只可能是文件在扫描过程中被改动
+			g.stopped = true
+			return
+		}
+	}
+}
+
+func (g *streamGenerator) output(w string) {
+	if len(g.Rules) == 0 {
+		g.emit(w)
+		return
+	}
+	for r := range rule.RunAsStream(g.Rules, w) {
+		if g.stopped {
+			// 消费掉剩余的结果, 让rule的goroutine正常退出
+			continue
+		}
+		g.emit(r)
+	}
+}
+
+func (g *streamGenerator) emit(w string) {
+	for _, fn := range g.Fns {
+		w = fn(w)
+	}
+	if w == "" {
+		return
+	}
+	select {
+	case g.ch <- w:
+	case <-g.ctx.Done():
+		g.stopped = true
+	}
+}
+
+type streamToken struct {
+	words []string // mask展开的结果, 数量可控, 保留在内存中
+	files []string // 字典文件, 按行读取
+	count int
+}
+
+func newStreamToken(mk string, dictNames []string, keywords map[string][]string) (*streamToken, error) {
+	token := &streamToken{}
+	if indexes := strings.TrimSuffix(strings.TrimPrefix(mk, "{?"), "}"); strings.HasPrefix(mk, "{?") && isDigits(indexes) {
+		for _, c := range indexes {
+			i, _ := strconv.Atoi(string(c))
+			if i >= len(dictNames) {
+				return nil, fmt.Errorf("%s: dict %d not found", mk, i)
+			}
+			count, err := countLines(dictNames[i])
+			if err != nil {
+				return nil, err
+			}
+			token.files = append(token.files, dictNames[i])
+			token.count += count
+		}
+		return token, nil
+	}
+
+	var err error
+	token.words, err = mask.Run(mk, nil, keywords)
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", mk, err)
+	}
+	token.count = len(token.words)
+	return token, nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// readLines 按行读取字典, 与loadFileToSlice保持一致, 去掉首尾空白并跳过空行. fn返回false时停止读取
+func readLines(filename string, fn func(string) bool) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !fn(line) {
+			break
+		}
+	}
+	return scanner.Err()
+}
+
+func countLines(filename string) (int, error) {
+	lineCountLocker.Lock()
+	count, ok := lineCountCache[filename]
+	lineCountLocker.Unlock()
+	if ok {
+		return count, nil
+	}
+	err := readLines(filename, func(string) bool {
+		count++
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	lineCountLocker.Lock()
+	lineCountCache[filename] = count
+	lineCountLocker.Unlock()
+	return count, nil
+}
+
+// lineCounts 已经统计过的字典行数, 保存到stat中
+func lineCounts(filenames []string) map[string]int {
+	lineCountLocker.Lock()
+	defer lineCountLocker.Unlock()
+	counts := make(map[string]int, len(filenames))
+	for _, filename := range filenames {
+		if count, ok := lineCountCache[filename]; ok {
+			counts[filename] = count
+		}
+	}
+	return counts
+}
+
+// restoreLineCounts 还原stat中保存的字典行数
+func restoreLineCounts(counts map[string]int) {
+	lineCountLocker.Lock()
+	defer lineCountLocker.Unlock()
+	for filename, count := range counts {
+		if _, ok := lineCountCache[filename]; !ok {
+			lineCountCache[filename] = count
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestStreamStopAtLimit 达到limit后取消ctx, 生成word的goroutine需要退出并关闭管道
+func TestStreamStopAtLimit(t *testing.T) {
+	var lines []string
+	for i := 0; i < 1000; i++ {
+		lines = append(lines, "word"+strconv.Itoa(i))
+	}
+	dict := filepath.Join(t.TempDir(), "dict.txt")
+	if err := os.WriteFile(dict, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := newWordStream("/{?0}", []string{dict}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stream.Count != 1000 {
+		t.Fatalf("count = %d, want 1000", stream.Count)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := stream.generate(ctx)
+	for i := 0; i < 10; i++ {
+		if w := <-ch; w != "/word"+strconv.Itoa(i) {
+			t.Fatalf("word %d = %s", i, w)
+		}
+	}
+	// 模拟pool.Run因limit正常结束, 不再读取管道
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	timeout := time.After(5 * time.Second)
+	remain := 0
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				if remain > 1 {
+					t.Errorf("generator emitted %d words after cancel", remain)
+				}
+				return
+			}
+			remain++
+		case <-timeout:
+			t.Fatal("generator goroutine not released after cancel")
+		}
+	}
+}
@@ -3,6 +3,7 @@ package internal
 import (
 	"github.com/chainreactors/spray/pkg"
 	"github.com/chainreactors/words"
+	"github.com/chainreactors/words/mask"
 	"github.com/chainreactors/words/rule"
 )
 
@@ -20,6 +21,12 @@ const (
 	CommonFileSource
 	UpgradeSource
 	RetrySource
+	PackSource
+	HarvestSource
+	LeakSource
+	SeedSource
+	JsSource
+	ApiSource
 )
 
 func newUnit(path string, source int) *Unit {
@@ -30,13 +37,30 @@ func newUnitWithNumber(path string, source int, number int) *Unit {
 	return &Unit{path: path, source: source, number: number}
 }
 
+func newWordUnit(path string, number int) *Unit {
+	return &Unit{path: path, source: WordSource, number: number, word: true}
+}
+
 type Unit struct {
 	number   int
 	path     string
 	source   int
+	word     bool // 来自字典的word, 完成后记录到stat中
 	retry    int
 	frontUrl string
-	depth    int // redirect depth
+	depth    int                // redirect depth
+	shape    string             // 校准用的路径形态
+	from     string             // 插件生成该路径的依据, 例如触发路径包的指纹
+	probe    chan *pkg.Baseline // 同步探测, 结果不进入后续的处理流程
+	api      *pkg.APIEndpoint   // 接口文档中声明的接口, 使用其中的method与body
+}
+
+// key 去重使用的key, 接口文档中同一路径的不同method与body视为不同的请求
+func (u *Unit) key(base string) string {
+	if u.api != nil {
+		return u.api.Method + " " + base + u.path + " " + u.api.Body
+	}
+	return base + u.path
 }
 
 type Task struct {
@@ -44,23 +68,93 @@ type Task struct {
 	depth   int
 	rule    []rule.Expression
 	origin  *Origin
+	policy  *Policy // 创建递归任务时选择的递归策略
 }
 
 func NewOrigin(stat *pkg.Statistor) *Origin {
 	return &Origin{Statistor: stat}
 }
 
+// resumeTasks 从stat中还原任务, 包括尚未开始的递归子任务
+func resumeTasks(stats pkg.Statistors) []*Task {
+	var tasks []*Task
+	started := make(map[string]bool)
+	for _, stat := range stats {
+		started[stat.BaseUrl] = true
+	}
+
+	for _, stat := range stats {
+		tasks = append(tasks, &Task{baseUrl: stat.BaseUrl, depth: stat.Depth, origin: NewOrigin(stat)})
+		for _, u := range stat.Recursions {
+			if started[u] {
+				continue
+			}
+			// 递归发现的目录还没来得及开始, 以父任务的配置重新生成
+			started[u] = true
+			child := pkg.NewStatistorFromStat(stat)
+			child.BaseUrl = u
+			child.Offset = 0
+			child.Completed = pkg.NewRanges()
+			child.Total = stat.Total
+			child.Depth = stat.Depth + 1
+			child.Recursions = nil
+			tasks = append(tasks, &Task{baseUrl: u, depth: child.Depth, origin: NewOrigin(child)})
+		}
+	}
+	return tasks
+}
+
 type Origin struct {
 	*pkg.Statistor
 	sum int
 }
 
+// Finished 字典中的word全部完成, 且没有遗留的插件任务
+func (o *Origin) Finished() bool {
+	if len(o.Additions) > 0 || o.End != o.Total {
+		return false
+	}
+	if o.Completed != nil {
+		// 失败的word不会被记录, 需要重新发送
+		return o.Completed.Count() >= o.Total-o.Offset
+	}
+	return true
+}
+
+// restoreGenerator 还原word中依赖的关键字, 命令行未指定装饰器时使用stat中保存的装饰器.
+// 多个任务并发还原, 关键字只在当前任务中生效, 不修改全局的mask.SpecialWords
+func (o *Origin) restoreGenerator(fns []func(string) string) (map[string][]string, []func(string) string) {
+	keywords := make(map[string][]string, len(mask.SpecialWords)+3)
+	for k, v := range mask.SpecialWords {
+		keywords[k] = v
+	}
+	if o.Generator != nil {
+		// word中的{@ext}, {@prefix}, {@suffix}依赖运行时注入的关键字, 需要先还原
+		if len(o.Generator.Extensions) > 0 {
+			keywords["ext"] = o.Generator.Extensions
+		}
+		if len(o.Generator.Prefixes) > 0 {
+			keywords["prefix"] = o.Generator.Prefixes
+		}
+		if len(o.Generator.Suffixes) > 0 {
+			keywords["suffix"] = o.Generator.Suffixes
+		}
+		if len(fns) == 0 {
+			fns = buildFns(o.Generator)
+		}
+	}
+	return keywords, fns
+}
+
 func (o *Origin) InitWorder(fns []func(string) string) (*words.Worder, error) {
 	var worder *words.Worder
-	wl, err := loadWordlist(o.Word, o.Dictionaries)
+	keywords, fns := o.restoreGenerator(fns)
+	wl, err := loadWordlist(o.Word, o.Dictionaries, keywords)
 	if err != nil {
 		return nil, err
 	}
+	// 还原自动选择的后缀, 保证word的序号与上次一致
+	wl = pkg.ExpandExtensions(wl, o.AutoExtensions)
 	worder = words.NewWorder(wl)
 	worder.Fns = fns
 	rules, err := loadRuleWithFiles(o.RuleFiles, o.RuleFilter)
@@ -76,3 +170,31 @@ func (o *Origin) InitWorder(fns []func(string) string) (*words.Worder, error) {
 
 	return worder, nil
 }
+
+func (o *Origin) InitStream(fns []func(string) string) (*wordStream, error) {
+	keywords, fns := o.restoreGenerator(fns)
+	if o.Generator != nil {
+		restoreLineCounts(o.Generator.LineCounts)
+	}
+	stream, err := newWordStream(o.Word, o.Dictionaries, keywords)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := loadRuleWithFiles(o.RuleFiles, o.RuleFilter)
+	if err != nil {
+		return nil, err
+	}
+	stream.Rules = rules
+	stream.Fns = fns
+	if len(rules) > 0 {
+		o.sum = len(rules) * stream.Count
+	} else {
+		o.sum = stream.Count
+	}
+	return stream, nil
+}
+
+// IsStream 原任务是否使用流式字典
+func (o *Origin) IsStream() bool {
+	return o.Generator != nil && o.Generator.Stream
+}
@@ -2,14 +2,18 @@ package internal
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/antonmedv/expr"
 	"github.com/antonmedv/expr/vm"
 	"github.com/chainreactors/logs"
+	"github.com/chainreactors/parsers/iutils"
 	"github.com/chainreactors/spray/pkg"
+	"github.com/chainreactors/spray/pkg/ihttp"
 	"github.com/chainreactors/words/mask"
 	"github.com/chainreactors/words/rule"
 	"io/ioutil"
 	"math/rand"
+	"net"
 	"net/url"
 	"path"
 	"path/filepath"
@@ -79,6 +83,51 @@ func parseStatus(preset []int, changed string) []int {
 	return preset
 }
 
+// buildFns 根据generator配置生成字典的装饰函数, 命令行与断点续传共用
+func buildFns(gen *pkg.Generator) []func(string) string {
+	var fns []func(string) string
+	if gen == nil {
+		return fns
+	}
+	if gen.Uppercase {
+		fns = append(fns, strings.ToUpper)
+	}
+	if gen.Lowercase {
+		fns = append(fns, strings.ToLower)
+	}
+
+	if len(gen.RemoveExtensions) > 0 {
+		rexts := gen.RemoveExtensions
+		fns = append(fns, func(s string) string {
+			if ext := parseExtension(s); iutils.StringsContains(rexts, ext) {
+				return strings.TrimSuffix(s, "."+ext)
+			}
+			return s
+		})
+	}
+
+	if len(gen.ExcludeExtensions) > 0 {
+		exexts := gen.ExcludeExtensions
+		fns = append(fns, func(s string) string {
+			if ext := parseExtension(s); iutils.StringsContains(exexts, ext) {
+				return ""
+			}
+			return s
+		})
+	}
+
+	if len(gen.Replaces) > 0 {
+		replaces := gen.Replaces
+		fns = append(fns, func(s string) string {
+			for k, v := range replaces {
+				s = strings.Replace(s, k, v, -1)
+			}
+			return s
+		})
+	}
+	return fns
+}
+
 func loadFileToSlice(filename string) ([]string, error) {
 	var ss []string
 	content, err := ioutil.ReadFile(filename)
@@ -115,6 +164,8 @@ func loadFileAndCombine(filename []string) (string, error) {
 }
 
 func loadFileWithCache(filename string) ([]string, error) {
+	dictLocker.Lock()
+	defer dictLocker.Unlock()
 	if dict, ok := dictCache[filename]; ok {
 		return dict, nil
 	}
@@ -138,19 +189,26 @@ func loadDictionaries(filenames []string) ([][]string, error) {
 	return dicts, nil
 }
 
-func loadWordlist(word string, dictNames []string) ([]string, error) {
-	if wl, ok := wordlistCache[word+strings.Join(dictNames, ",")]; ok {
+// loadWordlist keywords为nil时使用全局的mask.SpecialWords. 断点续传时多个任务并发调用
+func loadWordlist(word string, dictNames []string, keywords map[string][]string) ([]string, error) {
+	key := word + strings.Join(dictNames, ",")
+	for _, k := range []string{"ext", "prefix", "suffix"} {
+		key += "|" + strings.Join(keywords[k], ",")
+	}
+	cacheLocker.Lock()
+	defer cacheLocker.Unlock()
+	if wl, ok := wordlistCache[key]; ok {
 		return wl, nil
 	}
 	dicts, err := loadDictionaries(dictNames)
 	if err != nil {
 		return nil, err
 	}
-	wl, err := mask.Run(word, dicts, nil)
+	wl, err := mask.Run(word, dicts, keywords)
 	if err != nil {
 		return nil, err
 	}
-	wordlistCache[word] = wl
+	wordlistCache[key] = wl
 	return wl, nil
 }
 
@@ -276,3 +334,97 @@ func MatchWithGlobs(u string, globs []string) bool {
 	}
 	return false
 }
+
+// sampleShapes 从字典的前一部分中统计路径形态, 用于初始化时的校准. 其余的形态会在喷洒过程中发现时再校准
+func sampleShapes(wordlist []string, fns []func(string) string) []string {
+	var shapes []string
+	seen := make(map[string]bool)
+	for i, w := range wordlist {
+		if i >= 10000 || len(shapes) >= MaxShapes {
+			break
+		}
+		for _, fn := range fns {
+			w = fn(w)
+		}
+		shape := pkg.PathShape(w)
+		if shape != "" && !seen[shape] {
+			seen[shape] = true
+			shapes = append(shapes, shape)
+		}
+	}
+	return shapes
+}
+
+// splitShape 拆分出路径所在的目录与最后一级的形态, 例如 /api/v1/ => /api/, */  /api/user.json => /api/, *.json
+func splitShape(p string) (string, string) {
+	i := strings.LastIndex(strings.TrimSuffix(p, "/"), "/")
+	return p[:i+1], pkg.PathShape(p[i+1:])
+}
+
+// shortcutExpr 将逗号分割的数值与区间转换为表达式, 例如 200,300-399,>500, "all"匹配所有
+func shortcutExpr(field, value string) (string, error) {
+	var exps []string
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if v == "all" {
+			return "true", nil
+		}
+		if strings.HasPrefix(v, ">") || strings.HasPrefix(v, "<") {
+			if _, err := strconv.Atoi(v[1:]); err != nil {
+				return "", fmt.Errorf("%s: invalid value %s", field, v)
+			}
+			exps = append(exps, field+" "+v[:1]+" "+v[1:])
+		} else if i := strings.Index(v, "-"); i > 0 {
+			start, err1 := strconv.Atoi(v[:i])
+			end, err2 := strconv.Atoi(v[i+1:])
+			if err1 != nil || err2 != nil || start > end {
+				return "", fmt.Errorf("%s: invalid range %s", field, v)
+			}
+			exps = append(exps, fmt.Sprintf("(%s >= %d && %s <= %d)", field, start, field, end))
+		} else {
+			if _, err := strconv.Atoi(v); err != nil {
+				return "", fmt.Errorf("%s: invalid value %s", field, v)
+			}
+			exps = append(exps, field+" == "+v)
+		}
+	}
+	if len(exps) == 0 {
+		return "", fmt.Errorf("%s: empty value", field)
+	}
+	return "(" + strings.Join(exps, " || ") + ")", nil
+}
+
+// parseRetryPolicy 解析每种错误类型的重试次数, 例如 timeout:3,dns:0
+func parseRetryPolicy(s string) (map[string]int, error) {
+	policy := make(map[string]int)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, ":", 2)
+		if len(kv) != 2 || !iutils.StringsContains(ihttp.ErrClasses, kv[0]) {
+			return nil, fmt.Errorf("invalid retry policy %s, error class must be one of %s", item, strings.Join(ihttp.ErrClasses, ","))
+		}
+		count, err := strconv.Atoi(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry policy %s, %w", item, err)
+		}
+		policy[kv[0]] = count
+	}
+	return policy, nil
+}
+
+// hostPort 补全默认端口, 例如https://1.1.1.1返回1.1.1.1:443
+func hostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
@@ -0,0 +1,407 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+)
+
+var (
+	// APISpecNames 接口文档的文件名
+	APISpecNames = []string{
+		"swagger.json", "swagger.yaml", "swagger.yml", "openapi.json", "openapi.yaml", "openapi.yml",
+		"api-docs", "api-docs.json", "swagger-docs",
+	}
+	// GraphQLNames 可能是graphql接口的文件名
+	GraphQLNames = []string{"graphql", "graphiql", "gql", "graphql.php"}
+	// APISpecPaths 主动请求的接口文档与graphql地址
+	APISpecPaths = []string{
+		"/swagger.json", "/openapi.json", "/v2/api-docs", "/v3/api-docs", "/swagger-resources",
+		"/api/swagger.json", "/swagger/v1/swagger.json", "/api-docs", "/graphql", "/api/graphql",
+	}
+	// APIMethods 接口文档中可以声明的method
+	APIMethods = []string{"get", "post", "put", "delete", "patch", "head", "options"}
+	// APISafeMethods 不会修改数据的method, 其他method与graphql的mutation需要--api-unsafe
+	APISafeMethods  = []string{"GET", "HEAD", "OPTIONS"}
+	MaxAPIEndpoints = 2000 // 每个接口文档最多加入的接口数量
+
+	apiSpecRegexp = regexp.MustCompile(`(?m)(?:"(?:swagger|openapi)"\s*:|^(?:swagger|openapi)\s*:)`)
+)
+
+// GraphQLIntrospection 获取所有query与mutation, 以及参数与返回值类型的内省查询
+const GraphQLIntrospection = `{"query":"query{__schema{queryType{name} mutationType{name} types{name kind fields{name args{name type{kind name ofType{kind name ofType{kind name}}}} type{kind name ofType{kind name ofType{kind name}}}}}}}"}`
+
+// APIEndpoint 接口文档中声明的接口, Path中的参数已经替换为占位值
+type APIEndpoint struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Body        string `json:"body,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Raw         string `json:"raw,omitempty"`    // 文档中的原始声明, 例如 GET /users/{id}, query user
+	Unsafe      bool   `json:"unsafe,omitempty"` // 可能修改数据的接口, 例如PUT, DELETE与graphql的mutation
+}
+
+func (api *APIEndpoint) String() string {
+	return api.Raw
+}
+
+// IsAPISpec 根据路径判断是否可能是swagger/openapi文档
+func IsAPISpec(p string) bool {
+	name := path.Base(p)
+	for _, n := range APISpecNames {
+		if strings.EqualFold(name, n) {
+			return true
+		}
+	}
+	// springfox与springdoc, 例如 /v2/api-docs?group=default, /v3/api-docs/swagger-config
+	return strings.Contains(p, "/api-docs")
+}
+
+// IsAPISpecContent 根据内容的开头判断是否是swagger/openapi文档, 用于文件名不常见的文档
+func IsAPISpecContent(content []byte) bool {
+	if len(content) > 512 {
+		content = content[:512]
+	}
+	return apiSpecRegexp.Match(content)
+}
+
+// IsGraphQL 根据路径判断是否可能是graphql接口
+func IsGraphQL(p string) bool {
+	name := path.Base(p)
+	for _, n := range GraphQLNames {
+		if strings.EqualFold(name, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSwaggerResources 解析springfox的/swagger-resources, 返回每个分组的文档地址
+func ParseSwaggerResources(content []byte) []string {
+	var resources []struct {
+		Location string `json:"location"`
+		URL      string `json:"url"`
+	}
+	if err := json.Unmarshal(content, &resources); err != nil {
+		return nil
+	}
+	var locations []string
+	for _, r := range resources {
+		if r.URL != "" {
+			locations = append(locations, r.URL)
+		} else if r.Location != "" {
+			locations = append(locations, r.Location)
+		}
+	}
+	return locations
+}
+
+type apiSpec struct {
+	Swagger     string                                `json:"swagger"`
+	OpenAPI     string                                `json:"openapi"`
+	BasePath    string                                `json:"basePath"`
+	Servers     []struct{ URL string }                `json:"servers"`
+	Paths       map[string]map[string]json.RawMessage `json:"paths"`
+	Definitions map[string]*apiSchema                 `json:"definitions"`
+	Components  struct {
+		Schemas map[string]*apiSchema `json:"schemas"`
+	} `json:"components"`
+}
+
+type apiOperation struct {
+	Parameters  []*apiParameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema *apiSchema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+type apiParameter struct {
+	Name     string        `json:"name"`
+	In       string        `json:"in"`
+	Type     string        `json:"type"`
+	Example  interface{}   `json:"example"`
+	Default  interface{}   `json:"default"`
+	Enum     []interface{} `json:"enum"`
+	Schema   *apiSchema    `json:"schema"`
+	Required bool          `json:"required"`
+}
+
+type apiSchema struct {
+	Ref        string                `json:"$ref"`
+	Type       string                `json:"type"`
+	Example    interface{}           `json:"example"`
+	Default    interface{}           `json:"default"`
+	Enum       []interface{}         `json:"enum"`
+	Properties map[string]*apiSchema `json:"properties"`
+	Items      *apiSchema            `json:"items"`
+}
+
+// ParseAPISpec 解析swagger 2.0与openapi 3.x文档(json或yaml), 为每个接口生成带有占位参数的请求
+func ParseAPISpec(content []byte) []*APIEndpoint {
+	var spec apiSpec
+	if err := yaml.Unmarshal(content, &spec); err != nil || (spec.Swagger == "" && spec.OpenAPI == "") || len(spec.Paths) == 0 {
+		return nil
+	}
+	base := spec.BasePath
+	if len(spec.Servers) > 0 {
+		if u, err := url.Parse(spec.Servers[0].URL); err == nil {
+			base = u.Path
+		}
+	}
+	base = "/" + strings.Trim(base, "/")
+
+	var paths []string
+	for p := range spec.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var endpoints []*APIEndpoint
+	for _, p := range paths {
+		item := spec.Paths[p]
+		var common []*apiParameter
+		if raw, ok := item["parameters"]; ok {
+			json.Unmarshal(raw, &common)
+		}
+		for _, method := range APIMethods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+			var op apiOperation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				continue
+			}
+			endpoints = append(endpoints, spec.endpoint(method, strings.TrimSuffix(base, "/")+"/"+strings.TrimPrefix(p, "/"), append(append([]*apiParameter{}, common...), op.Parameters...), &op))
+			if len(endpoints) >= MaxAPIEndpoints {
+				return endpoints
+			}
+		}
+	}
+	return endpoints
+}
+
+func (spec *apiSpec) endpoint(method, p string, params []*apiParameter, op *apiOperation) *APIEndpoint {
+	api := &APIEndpoint{Method: strings.ToUpper(method), Raw: strings.ToUpper(method) + " " + p, Unsafe: true}
+	for _, m := range APISafeMethods {
+		if api.Method == m {
+			api.Unsafe = false
+		}
+	}
+	query := url.Values{}
+	form := url.Values{}
+	var body interface{}
+	for _, param := range params {
+		if param == nil {
+			// 文档中的"parameters": [null]
+			continue
+		}
+		value := spec.placeholder(param.schema(), 0)
+		switch param.In {
+		case "path":
+			p = strings.ReplaceAll(p, "{"+param.Name+"}", url.PathEscape(fmt.Sprint(value)))
+		case "query":
+			query.Set(param.Name, fmt.Sprint(value))
+		case "formData":
+			form.Set(param.Name, fmt.Sprint(value))
+		case "body":
+			body = value
+		}
+	}
+	if op.RequestBody != nil {
+		for contentType, media := range op.RequestBody.Content {
+			if strings.Contains(contentType, "json") {
+				body = spec.placeholder(media.Schema, 0)
+			} else if strings.Contains(contentType, "form") && media.Schema != nil {
+				if props, ok := spec.placeholder(media.Schema, 0).(map[string]interface{}); ok {
+					for k, v := range props {
+						form.Set(k, fmt.Sprint(v))
+					}
+				}
+			}
+		}
+	}
+
+	api.Path = p
+	if len(query) > 0 {
+		api.Path += "?" + query.Encode()
+	}
+	if body != nil {
+		content, _ := json.Marshal(body)
+		api.Body, api.ContentType = string(content), "application/json"
+	} else if len(form) > 0 {
+		api.Body, api.ContentType = form.Encode(), "application/x-www-form-urlencoded"
+	}
+	return api
+}
+
+func (param *apiParameter) schema() *apiSchema {
+	if param.Schema != nil {
+		return param.Schema
+	}
+	return &apiSchema{Type: param.Type, Example: param.Example, Default: param.Default, Enum: param.Enum}
+}
+
+// placeholder 根据schema生成占位值, 优先使用example, default与enum
+func (spec *apiSpec) placeholder(schema *apiSchema, depth int) interface{} {
+	if schema == nil || depth > 3 {
+		return "test"
+	}
+	if schema.Ref != "" {
+		name := schema.Ref[strings.LastIndex(schema.Ref, "/")+1:]
+		if s, ok := spec.Definitions[name]; ok {
+			return spec.placeholder(s, depth+1)
+		} else if s, ok := spec.Components.Schemas[name]; ok {
+			return spec.placeholder(s, depth+1)
+		}
+		return map[string]interface{}{}
+	}
+	if schema.Example != nil {
+		return schema.Example
+	} else if schema.Default != nil {
+		return schema.Default
+	} else if len(schema.Enum) > 0 {
+		return schema.Enum[0]
+	}
+	switch schema.Type {
+	case "integer", "number":
+		return 1
+	case "boolean":
+		return true
+	case "array":
+		return []interface{}{spec.placeholder(schema.Items, depth+1)}
+	case "object", "":
+		if len(schema.Properties) == 0 && schema.Type == "" {
+			return "test"
+		}
+		obj := make(map[string]interface{}, len(schema.Properties))
+		for k, v := range schema.Properties {
+			obj[k] = spec.placeholder(v, depth+1)
+		}
+		return obj
+	default:
+		return "test"
+	}
+}
+
+type graphQLType struct {
+	Kind   string       `json:"kind"`
+	Name   string       `json:"name"`
+	OfType *graphQLType `json:"ofType"`
+}
+
+// named 去掉NON_NULL与LIST之后的类型
+func (t *graphQLType) named() *graphQLType {
+	for t.OfType != nil && (t.Kind == "NON_NULL" || t.Kind == "LIST") {
+		t = t.OfType
+	}
+	return t
+}
+
+// ParseGraphQL 解析内省查询的结果, 为每个query与mutation生成只包含必选参数的请求
+func ParseGraphQL(p string, content []byte) []*APIEndpoint {
+	var resp struct {
+		Data struct {
+			Schema *struct {
+				QueryType    *struct{ Name string } `json:"queryType"`
+				MutationType *struct{ Name string } `json:"mutationType"`
+				Types        []struct {
+					Name   string `json:"name"`
+					Fields []struct {
+						Name string `json:"name"`
+						Args []struct {
+							Name string       `json:"name"`
+							Type *graphQLType `json:"type"`
+						} `json:"args"`
+						Type *graphQLType `json:"type"`
+					} `json:"fields"`
+				} `json:"types"`
+			} `json:"__schema"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(content, &resp); err != nil || resp.Data.Schema == nil {
+		return nil
+	}
+	schema := resp.Data.Schema
+	operations := make(map[string]string)
+	if schema.QueryType != nil {
+		operations[schema.QueryType.Name] = "query"
+	}
+	if schema.MutationType != nil {
+		operations[schema.MutationType.Name] = "mutation"
+	}
+
+	var endpoints []*APIEndpoint
+	for _, t := range schema.Types {
+		operation, ok := operations[t.Name]
+		if !ok {
+			continue
+		}
+		for _, field := range t.Fields {
+			var args []string
+			for _, arg := range field.Args {
+				if arg.Type == nil || arg.Type.Kind != "NON_NULL" {
+					continue
+				}
+				args = append(args, arg.Name+":"+graphQLPlaceholder(arg.Type.named()))
+			}
+			q := operation + "{" + field.Name
+			if len(args) > 0 {
+				q += "(" + strings.Join(args, ",") + ")"
+			}
+			if field.Type != nil {
+				switch field.Type.named().Kind {
+				case "OBJECT", "INTERFACE", "UNION":
+					q += "{__typename}"
+				}
+			}
+			q += "}"
+			body, _ := json.Marshal(map[string]string{"query": q})
+			endpoints = append(endpoints, &APIEndpoint{
+				Method:      "POST",
+				Path:        p,
+				Body:        string(body),
+				ContentType: "application/json",
+				Raw:         operation + " " + field.Name,
+				Unsafe:      operation == "mutation",
+			})
+			if len(endpoints) >= MaxAPIEndpoints {
+				return endpoints
+			}
+		}
+	}
+	return endpoints
+}
+
+func graphQLPlaceholder(t *graphQLType) string {
+	switch t.Name {
+	case "Int":
+		return "1"
+	case "Float":
+		return "1.0"
+	case "Boolean":
+		return "true"
+	default:
+		// String, ID以及自定义的scalar, enum与input类型无法推断, 使用字符串占位
+		return `"test"`
+	}
+}
+
+// APIClass 接口响应的分类, 2xx统一为200
+func APIClass(status int) string {
+	switch {
+	case status/100 == 2:
+		return "200"
+	case status == 401, status == 403, status == 405:
+		return fmt.Sprint(status)
+	default:
+		return "other"
+	}
+}
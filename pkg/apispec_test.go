@@ -0,0 +1,266 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var swaggerFixture = []byte(`{
+  "swagger": "2.0",
+  "basePath": "/api/",
+  "paths": {
+    "/users/{id}": {
+      "parameters": [{"name": "id", "in": "path", "type": "integer"}],
+      "get": {"parameters": [{"name": "q", "in": "query", "type": "string"}]},
+      "delete": {}
+    },
+    "/login": {
+      "post": {"parameters": [
+        {"name": "u", "in": "formData", "type": "string"},
+        {"name": "remember", "in": "formData", "type": "boolean", "default": false}
+      ]}
+    },
+    "/users": {
+      "put": {"parameters": [{"name": "user", "in": "body", "schema": {"$ref": "#/definitions/User"}}]}
+    },
+    "/status": {"head": {}, "trace": {}, "x-extension": "ignored"}
+  },
+  "definitions": {
+    "User": {"type": "object", "properties": {
+      "name": {"type": "string"},
+      "age": {"type": "integer", "example": 18},
+      "role": {"type": "string", "enum": ["admin", "user"]},
+      "tags": {"type": "array", "items": {"type": "string"}},
+      "parent": {"$ref": "#/definitions/User"}
+    }}
+  }
+}`)
+
+var openAPIFixture = []byte(`{
+  "openapi": "3.0.1",
+  "servers": [{"url": "https://api.example.com/v1/"}],
+  "paths": {
+    "/items": {
+      "post": {"requestBody": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Item"}}}}},
+      "patch": {"requestBody": {"content": {"application/x-www-form-urlencoded": {"schema": {"type": "object", "properties": {"id": {"type": "integer"}}}}}}}
+    },
+    "/items/{id}": {
+      "options": {"parameters": [{"name": "id", "in": "path", "schema": {"type": "string", "example": "a b/c"}}]}
+    }
+  },
+  "components": {"schemas": {"Item": {"properties": {"price": {"type": "number"}, "missing": {"$ref": "#/components/schemas/Missing"}}}}}
+}`)
+
+type endpointFixture struct {
+	Method, Path, Body, ContentType, Raw string
+	Unsafe                               bool
+}
+
+func endpointFixtures(endpoints []*APIEndpoint) []endpointFixture {
+	var fixtures []endpointFixture
+	for _, e := range endpoints {
+		fixtures = append(fixtures, endpointFixture{e.Method, e.Path, e.Body, e.ContentType, e.Raw, e.Unsafe})
+	}
+	return fixtures
+}
+
+func TestParseAPISpec(t *testing.T) {
+	cases := []struct {
+		name    string
+		content []byte
+		want    []endpointFixture
+	}{
+		// 循环引用的schema在深度上限处使用字符串占位
+		{"swagger", swaggerFixture, []endpointFixture{
+			{"POST", "/api/login", "remember=false&u=test", "application/x-www-form-urlencoded", "POST /api/login", true},
+			{"HEAD", "/api/status", "", "", "HEAD /api/status", false},
+			{"PUT", "/api/users", `{"age":18,"name":"test","parent":{"age":"test","name":"test","parent":"test","role":"test","tags":"test"},"role":"admin","tags":["test"]}`, "application/json", "PUT /api/users", true},
+			{"GET", "/api/users/1?q=test", "", "", "GET /api/users/{id}", false},
+			{"DELETE", "/api/users/1", "", "", "DELETE /api/users/{id}", true},
+		}},
+		{"openapi", openAPIFixture, []endpointFixture{
+			{"POST", "/v1/items", `{"missing":{},"price":1}`, "application/json", "POST /v1/items", true},
+			{"PATCH", "/v1/items", "id=1", "application/x-www-form-urlencoded", "PATCH /v1/items", true},
+			{"OPTIONS", "/v1/items/a%20b%2Fc", "", "", "OPTIONS /v1/items/{id}", false},
+		}},
+	}
+	for _, c := range cases {
+		if got := endpointFixtures(ParseAPISpec(c.content)); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestParseAPISpecMalformed(t *testing.T) {
+	malformed := []string{
+		"",
+		"<html></html>",
+		`{"swagger": "2.0"}`,
+		`{"paths": {"/a": {"get": {}}}}`,
+		`{"swagger": "2.0", "paths": []}`,
+		`{"swagger": 2, "paths": {"/a": {"get": {}}}}`,
+	}
+	for _, content := range malformed {
+		if got := ParseAPISpec([]byte(content)); got != nil {
+			t.Errorf("ParseAPISpec(%q) = %+v", content, endpointFixtures(got))
+		}
+	}
+
+	// 单个接口的声明不合法时跳过该接口, 不影响其他接口
+	tolerated := []struct {
+		content string
+		want    []endpointFixture
+	}{
+		{`{"swagger": "2.0", "paths": {"/a": {"get": "x", "head": {}}, "/b": null}}`, []endpointFixture{{"HEAD", "/a", "", "", "HEAD /a", false}}},
+		{`{"swagger": "2.0", "paths": {"/a": {"parameters": [null, {"name": "x", "in": "query"}], "get": {"parameters": [null]}}}}`, []endpointFixture{{"GET", "/a?x=test", "", "", "GET /a", false}}},
+		{`{"swagger": "2.0", "paths": {"/a": {"parameters": "x", "get": {"parameters": [{"in": "body", "schema": {"properties": {"p": null}, "items": null}}]}}}}`, []endpointFixture{{"GET", "/a", `{"p":"test"}`, "application/json", "GET /a", false}}},
+		{`{"openapi": "3.0.0", "servers": [{"url": "{scheme}://%zz"}], "paths": {"/a": {"get": {"requestBody": {"content": {"application/json": {}, "text/plain": {}}}}}}}`, []endpointFixture{{"GET", "/a", `"test"`, "application/json", "GET /a", false}}},
+	}
+	for _, c := range tolerated {
+		if got := endpointFixtures(ParseAPISpec([]byte(c.content))); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ParseAPISpec(%s):\n got %+v\nwant %+v", c.content, got, c.want)
+		}
+	}
+
+	for _, fixture := range [][]byte{swaggerFixture, openAPIFixture} {
+		for i := 0; i < len(fixture); i++ {
+			// 截断的json不是合法的文档
+			if got := ParseAPISpec(fixture[:i]); got != nil {
+				t.Fatalf("truncated at %d returned %+v", i, endpointFixtures(got))
+			}
+		}
+	}
+}
+
+func TestParseAPISpecLimit(t *testing.T) {
+	paths := make(map[string]interface{})
+	for i := 0; i < MaxAPIEndpoints; i++ {
+		paths[fmt.Sprintf("/a%d", i)] = map[string]interface{}{"get": map[string]interface{}{}, "post": map[string]interface{}{}}
+	}
+	content, _ := json.Marshal(map[string]interface{}{"swagger": "2.0", "paths": paths})
+	if got := ParseAPISpec(content); len(got) != MaxAPIEndpoints {
+		t.Errorf("got %d endpoints, want %d", len(got), MaxAPIEndpoints)
+	}
+}
+
+var graphQLFixture = []byte(`{"data": {"__schema": {
+  "queryType": {"name": "Query"},
+  "mutationType": {"name": "Mutation"},
+  "types": [
+    {"name": "Query", "fields": [
+      {"name": "user", "args": [
+        {"name": "id", "type": {"kind": "NON_NULL", "ofType": {"kind": "SCALAR", "name": "ID"}}},
+        {"name": "verbose", "type": {"kind": "SCALAR", "name": "Boolean"}}
+      ], "type": {"kind": "OBJECT", "name": "User"}},
+      {"name": "users", "args": [
+        {"name": "limit", "type": {"kind": "NON_NULL", "ofType": {"kind": "SCALAR", "name": "Int"}}},
+        {"name": "score", "type": {"kind": "NON_NULL", "ofType": {"kind": "SCALAR", "name": "Float"}}}
+      ], "type": {"kind": "NON_NULL", "ofType": {"kind": "LIST", "ofType": {"kind": "OBJECT", "name": "User"}}}},
+      {"name": "version", "args": [], "type": {"kind": "SCALAR", "name": "String"}},
+      {"name": "untyped"}
+    ]},
+    {"name": "Mutation", "fields": [
+      {"name": "deleteUser", "args": [{"name": "force", "type": {"kind": "NON_NULL", "ofType": {"kind": "SCALAR", "name": "Boolean"}}}], "type": {"kind": "SCALAR", "name": "Boolean"}}
+    ]},
+    {"name": "User", "fields": [{"name": "password", "args": []}]}
+  ]
+}}}`)
+
+func TestParseGraphQL(t *testing.T) {
+	want := []endpointFixture{
+		{"POST", "/graphql", `{"query":"query{user(id:\"test\"){__typename}}"}`, "application/json", "query user", false},
+		{"POST", "/graphql", `{"query":"query{users(limit:1,score:1.0){__typename}}"}`, "application/json", "query users", false},
+		{"POST", "/graphql", `{"query":"query{version}"}`, "application/json", "query version", false},
+		{"POST", "/graphql", `{"query":"query{untyped}"}`, "application/json", "query untyped", false},
+		{"POST", "/graphql", `{"query":"mutation{deleteUser(force:true)}"}`, "application/json", "mutation deleteUser", true},
+	}
+	if got := endpointFixtures(ParseGraphQL("/graphql", graphQLFixture)); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseGraphQL:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseGraphQLMalformed(t *testing.T) {
+	malformed := []string{
+		"",
+		`{"data": null}`,
+		`{"errors": [{"message": "introspection is disabled"}]}`,
+		`{"data": {"__schema": "x"}}`,
+	}
+	for _, content := range malformed {
+		if got := ParseGraphQL("/graphql", []byte(content)); got != nil {
+			t.Errorf("ParseGraphQL(%q) = %+v", content, endpointFixtures(got))
+		}
+	}
+
+	tolerated := []string{
+		`{"data": {"__schema": {}}}`,
+		`{"data": {"__schema": {"queryType": null, "types": [{"name": "Query", "fields": [{"name": "a"}]}]}}}`,
+		`{"data": {"__schema": {"queryType": {"name": "Query"}, "types": [null, {"name": "Query", "fields": [null]}]}}}`,
+		`{"data": {"__schema": {"queryType": {"name": "Query"}, "types": [{"name": "Query", "fields": [{"name": "a", "args": [null, {"name": "x", "type": {"kind": "NON_NULL"}}], "type": {"kind": "LIST"}}]}]}}}`,
+	}
+	for _, content := range tolerated {
+		ParseGraphQL("/graphql", []byte(content))
+	}
+	for i := 0; i < len(graphQLFixture); i++ {
+		if got := ParseGraphQL("/graphql", graphQLFixture[:i]); got != nil {
+			t.Fatalf("truncated at %d returned %+v", i, endpointFixtures(got))
+		}
+	}
+}
+
+func TestParseSwaggerResources(t *testing.T) {
+	content := []byte(`[{"name":"default","url":"/v2/api-docs?group=default","location":"/v2/api-docs"},{"name":"old","location":"/v2/api-docs?group=old"},{"name":"empty"}]`)
+	if got, want := ParseSwaggerResources(content), []string{"/v2/api-docs?group=default", "/v2/api-docs?group=old"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseSwaggerResources = %q, want %q", got, want)
+	}
+	for _, content := range []string{"", "{}", `[{"url":`, "null"} {
+		if got := ParseSwaggerResources([]byte(content)); got != nil {
+			t.Errorf("ParseSwaggerResources(%q) = %q", content, got)
+		}
+	}
+}
+
+func TestIsAPISpec(t *testing.T) {
+	paths := map[string]bool{
+		"/swagger.json":               true,
+		"/static/OpenAPI.YAML":        true,
+		"/v2/api-docs":                true,
+		"/v3/api-docs/swagger-config": true,
+		"/swagger-ui.html":            false,
+		"/docs/api.json":              false,
+	}
+	for p, want := range paths {
+		if got := IsAPISpec(p); got != want {
+			t.Errorf("IsAPISpec(%s) = %v, want %v", p, got, want)
+		}
+	}
+
+	contents := map[string]bool{
+		`{"swagger":"2.0"}`:                      true,
+		`{ "info": {}, "openapi" : "3.0.0" }`:    true,
+		"openapi: 3.0.0\ninfo:\n  title: x\n":    true,
+		"title: x\nswagger: '2.0'\n":             true,
+		`{"description":"swagger: not a spec"}`:  false,
+		string(make([]byte, 600)) + `"swagger":`: false,
+	}
+	for content, want := range contents {
+		if got := IsAPISpecContent([]byte(content)); got != want {
+			t.Errorf("IsAPISpecContent(%q) = %v, want %v", content, got, want)
+		}
+	}
+
+	if !IsGraphQL("/api/GraphQL") || IsGraphQL("/graphql/schema.json") {
+		t.Error("IsGraphQL mismatch")
+	}
+}
+
+func TestAPIClass(t *testing.T) {
+	cases := map[int]string{200: "200", 204: "200", 401: "401", 403: "403", 405: "405", 404: "other", 500: "other", 0: "other"}
+	for status, want := range cases {
+		if got := APIClass(status); got != want {
+			t.Errorf("APIClass(%d) = %s, want %s", status, got, want)
+		}
+	}
+}
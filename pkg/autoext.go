@@ -0,0 +1,99 @@
+package pkg
+
+import (
+	"bytes"
+	"strings"
+)
+
+// TechStack 技术栈与对应的常见后缀. Frameworks为指纹名中的关键字, Headers为响应头中的关键字, 均不区分大小写
+type TechStack struct {
+	Name       string
+	Extensions []string
+	Frameworks []string
+	Headers    []string
+}
+
+var TechStacks = []*TechStack{
+	{
+		Name:       "php",
+		Extensions: []string{".php"},
+		Frameworks: []string{"php", "wordpress", "laravel", "drupal", "joomla", "discuz", "dedecms", "ecshop", "phpcms", "typecho", "yii", "codeigniter", "symfony", "magento"},
+		Headers:    []string{"x-powered-by: php", "phpsessid"},
+	},
+	{
+		Name:       "asp.net",
+		Extensions: []string{".aspx", ".ashx", ".asmx"},
+		Frameworks: []string{"asp.net", "aspx", "sharepoint", "umbraco", "dotnetnuke", "kentico", "sitecore"},
+		Headers:    []string{"x-aspnet-version", "x-aspnetmvc-version", "x-powered-by: asp.net", "asp.net_sessionid"},
+	},
+	{
+		Name:       "asp",
+		Extensions: []string{".asp"},
+		Frameworks: []string{"iis", "microsoft-iis"},
+		Headers:    []string{"server: microsoft-iis", "aspsessionid"},
+	},
+	{
+		Name:       "java",
+		Extensions: []string{".jsp", ".do", ".action"},
+		Frameworks: []string{"java", "jsp", "tomcat", "jboss", "weblogic", "websphere", "spring", "struts", "jetty", "resin", "shiro", "jeecg", "ruoyi"},
+		Headers:    []string{"jsessionid", "x-powered-by: servlet", "x-powered-by: jsp", "server: apache-coyote", "server: jetty", "server: resin"},
+	},
+	{
+		Name:       "python",
+		Frameworks: []string{"django", "flask", "tornado", "python"},
+		Headers:    []string{"server: wsgiserver", "server: gunicorn", "server: tornadoserver", "csrftoken"},
+	},
+}
+
+// ProbeExtensions 通过随机路径探测服务端是否对这些后缀有单独的处理, 例如php-fpm的"File not found.", IIS的ASP.NET错误页
+var ProbeExtensions = []string{".php", ".aspx", ".jsp", ".asp"}
+
+// DetectTechStack 根据指纹与响应头识别技术栈, 返回命中的技术栈与依据
+func DetectTechStack(bl *Baseline) ([]*TechStack, []string) {
+	var stacks []*TechStack
+	var evidences []string
+	header := bytes.ToLower(bl.Header)
+	for _, stack := range TechStacks {
+		if evidence, ok := stack.match(bl, header); ok {
+			stacks = append(stacks, stack)
+			evidences = append(evidences, stack.Name+": "+evidence)
+		}
+	}
+	return stacks, evidences
+}
+
+func (stack *TechStack) match(bl *Baseline, header []byte) (string, bool) {
+	for name := range bl.Frameworks {
+		lower := strings.ToLower(name)
+		for _, f := range stack.Frameworks {
+			if strings.Contains(lower, f) {
+				return "framework " + name, true
+			}
+		}
+	}
+	for _, h := range stack.Headers {
+		if bytes.Contains(header, []byte(h)) {
+			return "header " + h, true
+		}
+	}
+	return "", false
+}
+
+// ExpandExtensions 为没有后缀的word追加后缀, 保留原始的word. 已经带有后缀或者以"/"结尾的word不做处理
+func ExpandExtensions(words []string, exts []string) []string {
+	if len(exts) == 0 {
+		return words
+	}
+	expanded := make([]string, 0, len(words)*(len(exts)+1))
+	for _, w := range words {
+		expanded = append(expanded, w)
+		name := w[strings.LastIndex(w, "/")+1:]
+		if name == "" || strings.Contains(name, ".") {
+			continue
+		}
+		for _, ext := range exts {
+			expanded = append(expanded, w+ext)
+		}
+	}
+	return expanded
+}
@@ -0,0 +1,111 @@
+package pkg
+
+import (
+	"github.com/chainreactors/parsers/iutils"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ArchiveExtensions 目录打包与上下文备份文件的后缀
+	ArchiveExtensions = []string{"zip", "rar", "tar.gz", "tgz", "tar", "7z", "gz"}
+	// CommonSLDs 二级域名后缀, 生成站点名时跳过
+	CommonSLDs = []string{"com", "net", "org", "edu", "gov", "co", "ac"}
+
+	bakYearRegexp  = regexp.MustCompile(`\b(?:19|20)\d{2}\b`)
+	bakDateRegexp  = regexp.MustCompile(`\b20\d{2}[-/.]?[01]\d[-/.]?[0-3]\d\b`)
+	bakTitleRegexp = regexp.MustCompile(`[A-Za-z0-9][\w\-]{2,}`)
+	MaxBakContexts = 5 // 每种上下文(标题, 年份, 日期)最多使用的数量
+)
+
+// ArchiveCandidates 目录的打包文件, 位于上级目录中. 例如 /a/b/ 生成 /a/b.zip, /a/b.tar.gz, /a/b.rar
+func ArchiveCandidates(dir string) []string {
+	p := strings.TrimSuffix(dir, "/")
+	i := strings.LastIndex(p, "/")
+	if i == -1 || p[i+1:] == "" {
+		return nil
+	}
+	parent, name := p[:i+1], p[i+1:]
+	candidates := make([]string, len(ArchiveExtensions))
+	for j, ext := range ArchiveExtensions {
+		candidates[j] = parent + name + "." + ext
+	}
+	return candidates
+}
+
+// ContextBakNames 根据站点名, 页面标题, 以及页面中出现的年份与日期生成备份文件名, 不包含后缀
+func ContextBakNames(bl *Baseline, host string, now time.Time) []string {
+	sites := SiteNames(host)
+	var names []string
+	names = append(names, sites...)
+
+	if bl != nil && bl.SprayResult != nil {
+		titles := bakTitleRegexp.FindAllString(bl.Title, -1)
+		if len(titles) > MaxBakContexts {
+			titles = titles[:MaxBakContexts]
+		}
+		names = append(names, titles...)
+		if len(titles) > 1 {
+			names = append(names, strings.Join(titles, ""), strings.Join(titles, "_"))
+		}
+	}
+
+	years := []string{strconv.Itoa(now.Year()), strconv.Itoa(now.Year() - 1), strconv.Itoa(now.Year() - 2)}
+	dates := []string{now.Format("20060102")}
+	if bl != nil {
+		years = append(years, firstMatches(bakYearRegexp, bl.Body)...)
+		for _, d := range firstMatches(bakDateRegexp, bl.Body) {
+			dates = append(dates, strings.NewReplacer("-", "", "/", "", ".", "").Replace(d))
+		}
+	}
+	years, dates = RemoveDuplication(years), RemoveDuplication(dates)
+	names = append(names, years...)
+	names = append(names, dates...)
+	if len(sites) > 0 {
+		// 只使用最短的站点名与时间组合, 例如example2024, example_20240101
+		site := sites[len(sites)-1]
+		for _, t := range append(years, dates...) {
+			names = append(names, site+t, site+"_"+t)
+		}
+	}
+	return RemoveDuplication(names)
+}
+
+// SiteNames 从域名中提取站点名, 例如 www.example.com.cn 生成 example, example.com.cn, www.example.com.cn
+func SiteNames(host string) []string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "" || net.ParseIP(host) != nil {
+		return nil
+	}
+	labels := strings.Split(strings.TrimSuffix(host, "."), ".")
+	names := []string{host}
+	if labels[0] == "www" && len(labels) > 2 {
+		labels = labels[1:]
+		names = append(names, strings.Join(labels, "."))
+	}
+	for i := len(labels) - 2; i >= 0; i-- {
+		if !iutils.StringsContains(CommonSLDs, labels[i]) || i == 0 {
+			names = append(names, labels[i])
+			break
+		}
+	}
+	return RemoveDuplication(names)
+}
+
+func firstMatches(reg *regexp.Regexp, content []byte) []string {
+	var ss []string
+	for _, m := range reg.FindAll(content, -1) {
+		if s := string(m); !iutils.StringsContains(ss, s) {
+			ss = append(ss, s)
+			if len(ss) >= MaxBakContexts {
+				break
+			}
+		}
+	}
+	return ss
+}
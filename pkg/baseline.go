@@ -2,13 +2,18 @@ package pkg
 
 import (
 	"bytes"
+	"encoding/json"
 	"github.com/chainreactors/parsers"
 	"github.com/chainreactors/parsers/iutils"
 	"github.com/chainreactors/spray/pkg/ihttp"
 	"net/url"
+	"regexp"
 	"strings"
+	"sync"
 )
 
+var regexpCache sync.Map
+
 func NewBaseline(u, host string, resp *ihttp.Response) *Baseline {
 	bl := &Baseline{
 		SprayResult: &parsers.SprayResult{
@@ -42,6 +47,8 @@ func NewBaseline(u, host string, resp *ihttp.Response) *Baseline {
 		} else {
 			bl.BodyLength = i
 		}
+		bl.Words = len(bytes.Fields(bl.Body))
+		bl.Lines = bytes.Count(bl.Body, []byte("\n")) + 1
 	}
 
 	bl.Raw = append(bl.Header, bl.Body...)
@@ -101,18 +108,53 @@ func NewInvalidBaseline(u, host string, resp *ihttp.Response, reason string) *Ba
 
 type Baseline struct {
 	*parsers.SprayResult
-	Unique    uint16   `json:"-"`
-	Url       *url.URL `json:"-"`
-	Dir       bool     `json:"-"`
-	Chunked   bool     `json:"-"`
-	Body      []byte   `json:"-"`
-	Header    []byte   `json:"-"`
-	Raw       []byte   `json:"-"`
-	Recu      bool     `json:"-"`
-	RecuDepth int      `json:"-"`
-	URLs      []string `json:"-"`
-	Collected bool     `json:"-"`
-	Retry     int      `json:"-"`
+	Unique    uint16       `json:"-"`
+	Url       *url.URL     `json:"-"`
+	Dir       bool         `json:"-"`
+	Chunked   bool         `json:"-"`
+	Body      []byte       `json:"-"`
+	Header    []byte       `json:"-"`
+	Raw       []byte       `json:"-"`
+	Recu      bool         `json:"-"`
+	RecuDepth int          `json:"-"`
+	URLs      []string     `json:"-"`
+	Collected bool         `json:"-"`
+	Retry     int          `json:"-"`
+	Cluster   int          `json:"cluster,omitempty"` // 响应聚类的id
+	Words     int          `json:"words"`
+	Lines     int          `json:"lines"`
+	Waf       string       `json:"waf,omitempty"`         // 拦截该请求的waf厂商
+	ErrClass  string       `json:"error_class,omitempty"` // 请求错误的分类, 例如timeout, reset, dns
+	Address   string       `json:"address,omitempty"`     // host模式下实际连接的ip:port
+	Cert      string       `json:"cert,omitempty"`        // host模式下证书的CN与SAN
+	From      string       `json:"from,omitempty"`        // 插件生成该路径的依据, 例如pack:spring-boot(SpringBoot)
+	API       *APIEndpoint `json:"api,omitempty"`         // 接口文档中声明的接口
+	APIClass  string       `json:"api_class,omitempty"`   // 接口的响应分类, 200/401/403/405/other
+}
+
+// Jsonify 在SprayResult的基础上, 输出Baseline中额外记录的字段
+func (bl *Baseline) Jsonify() string {
+	content, err := json.Marshal(bl)
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
+
+// MatchRegexp 正则匹配完整的响应, 用于--mr与--fr
+func (bl *Baseline) MatchRegexp(pattern string) bool {
+	var reg *regexp.Regexp
+	if r, ok := regexpCache.Load(pattern); ok {
+		reg = r.(*regexp.Regexp)
+	} else {
+		var err error
+		reg, err = regexp.Compile(pattern)
+		if err != nil {
+			return false
+		}
+		regexpCache.Store(pattern, reg)
+	}
+	return reg.Match(bl.Raw)
 }
 
 func (bl *Baseline) IsDir() bool {
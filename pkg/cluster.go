@@ -0,0 +1,179 @@
+package pkg
+
+import (
+	"bytes"
+	"github.com/chainreactors/parsers"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	MaxClusters       = 256
+	MaxClusterMembers = 256 // 每个类在判断之前最多暂存的成员, 超过上限的成员直接输出
+	ClusterBucketSize = 64
+	// 每个请求都会变化的header, 不参与聚类
+	volatileHeaders = map[string]bool{"date": true, "content-length": true, "expires": true, "last-modified": true, "etag": true, "age": true}
+)
+
+// NewClusters 在线的响应聚类, 当某一类响应在所有有效结果中的占比超过ratio且数量超过min时, 这一类都会被标记为soft-404
+func NewClusters(ratio float64, min int) *Clusters {
+	return &Clusters{ratio: ratio, min: min}
+}
+
+type Clusters struct {
+	locker   sync.Mutex
+	clusters []*Cluster
+	total    int
+	ratio    float64
+	min      int
+}
+
+type Cluster struct {
+	ID      int
+	Count   int
+	Fuzzy   bool
+	feature *ClusterFeature
+	held    []*Baseline // 数量达到min之前无法判断是否为soft-404, 暂存的成员
+}
+
+type ClusterFeature struct {
+	Status       int
+	LengthBucket int
+	Words        int
+	Lines        int
+	Title        string
+	ContentType  string
+	Simhash      string
+	Headers      string
+}
+
+func NewClusterFeature(bl *Baseline) *ClusterFeature {
+	f := &ClusterFeature{
+		Status:       bl.Status,
+		LengthBucket: bl.BodyLength / ClusterBucketSize,
+		Words:        bl.Words,
+		Lines:        bl.Lines,
+		Title:        bl.Title,
+		ContentType:  bl.ContentType,
+		Headers:      headerNames(bl.Header),
+	}
+	if bl.Hashes != nil {
+		f.Simhash = bl.BodySimhash
+	}
+	return f
+}
+
+// Similar 状态码, content-type, title与header集合必须一致, 长度, 词数, 行数允许小范围的浮动(例如回显了路径或者随机token)
+func (f *ClusterFeature) Similar(other *ClusterFeature) bool {
+	if f.Status != other.Status || f.ContentType != other.ContentType || f.Title != other.Title || f.Headers != other.Headers {
+		return false
+	}
+	if abs(f.LengthBucket-other.LengthBucket) > 1 || !near(f.Words, other.Words) || !near(f.Lines, other.Lines) {
+		return false
+	}
+	if f.Simhash != "" && other.Simhash != "" {
+		return parsers.SimhashCompare(f.Simhash, other.Simhash) < Distance
+	}
+	return true
+}
+
+// Add 将响应加入相似的类中, 返回所属的类, 该响应是否被暂存, 以及这个类是否刚刚被标记为soft-404. 类的数量达到上限后不再新建, 返回nil.
+// 类的数量达到min之前无法判断是否为soft-404, 成员暂存在类中, 达到min后通过Release取出
+func (cs *Clusters) Add(bl *Baseline) (cluster *Cluster, held bool, marked bool) {
+	feature := NewClusterFeature(bl)
+	cs.locker.Lock()
+	defer cs.locker.Unlock()
+	cs.total++
+	for _, c := range cs.clusters {
+		if c.feature.Similar(feature) {
+			cluster = c
+			break
+		}
+	}
+	if cluster == nil {
+		if len(cs.clusters) >= MaxClusters {
+			return nil, false, false
+		}
+		cluster = &Cluster{ID: len(cs.clusters) + 1, feature: feature}
+		cs.clusters = append(cs.clusters, cluster)
+	}
+	cluster.Count++
+	if !cluster.Fuzzy && cluster.Count >= cs.min && float64(cluster.Count)/float64(cs.total) >= cs.ratio {
+		cluster.Fuzzy = true
+		return cluster, false, true
+	}
+	if !cluster.Fuzzy && cluster.Count < cs.min && len(cluster.held) < MaxClusterMembers {
+		cluster.held = append(cluster.held, bl)
+		return cluster, true, false
+	}
+	return cluster, false, false
+}
+
+// Release 返回暂存的成员并清空, 每个成员只会返回一次
+func (cs *Clusters) Release(c *Cluster) []*Baseline {
+	cs.locker.Lock()
+	defer cs.locker.Unlock()
+	held := c.held
+	c.held = nil
+	return held
+}
+
+// ReleaseAll 任务结束时仍未达到min的类不会再被标记, 返回所有暂存的成员
+func (cs *Clusters) ReleaseAll() []*Baseline {
+	cs.locker.Lock()
+	defer cs.locker.Unlock()
+	var held []*Baseline
+	for _, c := range cs.clusters {
+		held = append(held, c.held...)
+		c.held = nil
+	}
+	return held
+}
+
+// Held 是否还有暂存的成员
+func (cs *Clusters) Held() bool {
+	cs.locker.Lock()
+	defer cs.locker.Unlock()
+	for _, c := range cs.clusters {
+		if len(c.held) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (cs *Clusters) Total() int {
+	cs.locker.Lock()
+	defer cs.locker.Unlock()
+	return cs.total
+}
+
+func headerNames(header []byte) string {
+	var names []string
+	for _, line := range bytes.Split(header, []byte("\n")) {
+		i := bytes.IndexByte(line, ':')
+		if i <= 0 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(string(line[:i])))
+		if !volatileHeaders[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func near(a, b int) bool {
+	// 允许10%或者2以内的浮动
+	d := abs(a - b)
+	return d <= 2 || d*10 <= a || d*10 <= b
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
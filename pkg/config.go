@@ -3,6 +3,7 @@ package pkg
 import (
 	"github.com/antonmedv/expr/vm"
 	"github.com/chainreactors/words/rule"
+	"time"
 )
 
 type SprayMod int
@@ -40,11 +41,31 @@ type Config struct {
 	FuzzyCh         chan *Baseline
 	Fuzzy           bool
 	IgnoreWaf       bool
+	WafProbe        bool
 	Crawl           bool
 	Scope           []string
 	Active          bool
 	Bak             bool
 	Common          bool
+	Pack            bool
+	Leak            bool
+	Seed            bool
+	JsAnalyze       bool
+	API             bool
+	APIUnsafe       bool
 	Retry           int
+	RetryPolicy     map[string]int // 每种错误类型的重试次数
+	RetryBackoff    int            // 重试的基础间隔(ms)
+	Deadline        time.Time      // 全局的停止分发时间
+	TargetTime      int            // 单个目标的时间预算(s)
+	TargetRequests  int            // 单个目标的请求数预算
+	MaxRecursion    int            // 递归策略指定的最大递归深度, 为0时使用--depth
 	RandomUserAgent bool
+	Shapes          []string // 字典中出现的路径形态, 初始化时校准
+	Dedup           *Deduplicator
+	Harvester       *Harvester // 为nil时不收集单词
+	ClusterRatio    float64
+	ClusterMin      int
+	CoolDown        int // 封禁后首次冷却的时间(秒), 0为直接退出
+	MaxBanTime      int
 }
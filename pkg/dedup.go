@@ -0,0 +1,224 @@
+package pkg
+
+import (
+	"hash/fnv"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	DefaultDedupThreshold         = 100000
+	DefaultDedupFPRate    float64 = 0.0001
+)
+
+// NewDeduplicator 全局的url去重, 数量低于threshold时使用精确的map, 超过后转移到可扩展的bloom filter中, 保证内存占用有上限
+func NewDeduplicator(threshold int, fpRate float64) *Deduplicator {
+	if threshold <= 0 {
+		threshold = DefaultDedupThreshold
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = DefaultDedupFPRate
+	}
+	return &Deduplicator{
+		exact:     make(map[string]struct{}),
+		threshold: threshold,
+		fpRate:    fpRate,
+	}
+}
+
+type Deduplicator struct {
+	locker    sync.Mutex
+	exact     map[string]struct{}
+	bloom     *ScalableBloom
+	threshold int
+	fpRate    float64
+	count     int
+}
+
+// Add 添加url, 如果是第一次出现返回true. 进入bloom filter后存在一定的误判率, 误判时会跳过本该请求的url
+func (d *Deduplicator) Add(u string) bool {
+	key := NormalizeURL(u)
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	if d.bloom != nil {
+		if d.bloom.Test(key) {
+			return false
+		}
+		d.bloom.Add(key)
+		d.count++
+		return true
+	}
+
+	if _, ok := d.exact[key]; ok {
+		return false
+	}
+	d.exact[key] = struct{}{}
+	d.count++
+	if len(d.exact) >= d.threshold {
+		// 超过阈值, 将精确集合迁移到bloom filter中, 释放map
+		d.bloom = NewScalableBloom(d.threshold, d.fpRate)
+		for k := range d.exact {
+			d.bloom.Add(k)
+		}
+		d.exact = nil
+	}
+	return true
+}
+
+func (d *Deduplicator) Count() int {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	return d.count
+}
+
+// IsBloom 是否已经切换到bloom filter
+func (d *Deduplicator) IsBloom() bool {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	return d.bloom != nil
+}
+
+// FalsePositiveRate 当前的估计误判率, 精确集合阶段为0
+func (d *Deduplicator) FalsePositiveRate() float64 {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	if d.bloom == nil {
+		return 0
+	}
+	return d.bloom.FalsePositiveRate()
+}
+
+// NormalizeURL 统一scheme与host的大小写, 去掉默认端口与fragment, 对query参数排序. 不处理path中的"//", 部分中间件中它是有意义的
+func NormalizeURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	host := strings.ToLower(parsed.Host)
+	if (parsed.Scheme == "http" && strings.HasSuffix(host, ":80")) || (parsed.Scheme == "https" && strings.HasSuffix(host, ":443")) {
+		host = host[:strings.LastIndex(host, ":")]
+	}
+	parsed.Host = host
+	parsed.Fragment = ""
+	if parsed.RawQuery != "" {
+		params := strings.Split(parsed.RawQuery, "&")
+		sort.Strings(params)
+		parsed.RawQuery = strings.Join(params, "&")
+	}
+	return parsed.String()
+}
+
+// NewScalableBloom 可扩展的bloom filter, 每个子filter写满后新建一个容量翻倍, 误判率减半的子filter,
+// 总误判率收敛于 fpRate. 参考 Almeida et al. "Scalable Bloom Filters"
+func NewScalableBloom(capacity int, fpRate float64) *ScalableBloom {
+	sb := &ScalableBloom{
+		capacity: capacity,
+		fpRate:   fpRate,
+	}
+	sb.grow()
+	return sb
+}
+
+type ScalableBloom struct {
+	filters  []*bloomFilter
+	capacity int
+	fpRate   float64
+}
+
+func (sb *ScalableBloom) grow() {
+	n := len(sb.filters)
+	capacity := sb.capacity << uint(n)
+	// 第i个子filter的误判率为 fpRate * (1/2)^(i+1), 级数和为fpRate
+	p := sb.fpRate * math.Pow(0.5, float64(n+1))
+	sb.filters = append(sb.filters, newBloomFilter(capacity, p))
+}
+
+func (sb *ScalableBloom) Add(s string) {
+	last := sb.filters[len(sb.filters)-1]
+	if last.count >= last.capacity {
+		sb.grow()
+		last = sb.filters[len(sb.filters)-1]
+	}
+	last.add(s)
+}
+
+func (sb *ScalableBloom) Test(s string) bool {
+	for _, f := range sb.filters {
+		if f.test(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (sb *ScalableBloom) FalsePositiveRate() float64 {
+	miss := 1.0
+	for _, f := range sb.filters {
+		miss *= 1 - f.falsePositiveRate()
+	}
+	return 1 - miss
+}
+
+func newBloomFilter(capacity int, p float64) *bloomFilter {
+	// m = -n*ln(p)/ln(2)^2, k = m/n*ln(2)
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint64(math.Round(float64(m) / float64(capacity) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &bloomFilter{
+		bits:     make([]uint64, (m+63)/64),
+		m:        m,
+		k:        k,
+		capacity: capacity,
+	}
+}
+
+type bloomFilter struct {
+	bits     []uint64
+	m        uint64
+	k        uint64
+	count    int
+	capacity int
+}
+
+func (f *bloomFilter) hashes(s string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	h1 := h.Sum64()
+	h.Write([]byte{0})
+	h2 := h.Sum64() | 1
+	return h1, h2
+}
+
+func (f *bloomFilter) add(s string) {
+	h1, h2 := f.hashes(s)
+	for i := uint64(0); i < f.k; i++ {
+		bit := (h1 + i*h2) % f.m
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+	f.count++
+}
+
+func (f *bloomFilter) test(s string) bool {
+	h1, h2 := f.hashes(s)
+	for i := uint64(0); i < f.k; i++ {
+		bit := (h1 + i*h2) % f.m
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *bloomFilter) falsePositiveRate() float64 {
+	// (1 - e^(-kn/m))^k
+	return math.Pow(1-math.Exp(-float64(f.k)*float64(f.count)/float64(f.m)), float64(f.k))
+}
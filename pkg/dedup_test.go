@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDeduplicatorExact(t *testing.T) {
+	d := NewDeduplicator(1000, 0.01)
+	for i := 0; i < 999; i++ {
+		if !d.Add(fmt.Sprintf("http://example.com/%d", i)) {
+			t.Fatalf("first add of %d reported duplicate", i)
+		}
+	}
+	if d.IsBloom() || d.FalsePositiveRate() != 0 {
+		t.Fatal("deduplicator switched to bloom below threshold")
+	}
+	for i := 0; i < 999; i++ {
+		if d.Add(fmt.Sprintf("http://example.com/%d", i)) {
+			t.Fatalf("second add of %d not reported duplicate", i)
+		}
+	}
+	if d.Count() != 999 {
+		t.Errorf("Count() = %d, want 999", d.Count())
+	}
+}
+
+func TestDeduplicatorNormalize(t *testing.T) {
+	d := NewDeduplicator(0, 0)
+	cases := []struct {
+		u   string
+		new bool
+	}{
+		{"http://Example.com:80/a?b=2&a=1#x", true},
+		{"HTTP://example.com/a?a=1&b=2", false},
+		{"https://example.com:443/a?a=1&b=2", true},
+		{"https://example.com/a?b=2&a=1", false},
+		{"http://example.com//a?a=1&b=2", true},
+		{"http://example.com/A?a=1&b=2", true},
+	}
+	for _, c := range cases {
+		if got := d.Add(c.u); got != c.new {
+			t.Errorf("Add(%s) = %v, want %v", c.u, got, c.new)
+		}
+	}
+}
+
+func TestDeduplicatorGrowth(t *testing.T) {
+	threshold, total := 1000, 20000
+	d := NewDeduplicator(threshold, 0.001)
+	for i := 0; i < total; i++ {
+		d.Add(fmt.Sprintf("http://example.com/%d", i))
+	}
+	if !d.IsBloom() {
+		t.Fatal("deduplicator should switch to bloom past threshold")
+	}
+	if n := len(d.bloom.filters); n < 2 {
+		t.Errorf("bloom should grow past its initial capacity, got %d filters", n)
+	}
+	// 已经加入的url不会漏判
+	for i := 0; i < total; i++ {
+		if d.Add(fmt.Sprintf("http://example.com/%d", i)) {
+			t.Fatalf("url %d lost after migrating to bloom", i)
+		}
+	}
+	if rate := d.FalsePositiveRate(); rate <= 0 || rate > 0.001 {
+		t.Errorf("estimated false positive rate %f out of (0, 0.001]", rate)
+	}
+}
+
+func TestScalableBloomFalsePositive(t *testing.T) {
+	for _, fpRate := range []float64{0.01, 0.001} {
+		sb := NewScalableBloom(1000, fpRate)
+		n := 50000
+		for i := 0; i < n; i++ {
+			sb.Add(fmt.Sprintf("in-%d", i))
+		}
+		var fp int
+		trials := 100000
+		for i := 0; i < trials; i++ {
+			if sb.Test(fmt.Sprintf("out-%d", i)) {
+				fp++
+			}
+		}
+		// 实际误判率允许在估计值的两倍以内浮动
+		if rate := float64(fp) / float64(trials); rate > fpRate*2 {
+			t.Errorf("fpRate %f: measured false positive rate %f", fpRate, rate)
+		}
+		if est := sb.FalsePositiveRate(); est > fpRate {
+			t.Errorf("fpRate %f: estimated false positive rate %f exceeds bound", fpRate, est)
+		}
+	}
+}
+
+func TestNewDeduplicatorDefaults(t *testing.T) {
+	d := NewDeduplicator(-1, 2)
+	if d.threshold != DefaultDedupThreshold || d.fpRate != DefaultDedupFPRate {
+		t.Errorf("defaults = %d, %f", d.threshold, d.fpRate)
+	}
+}
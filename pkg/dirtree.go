@@ -0,0 +1,134 @@
+package pkg
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrDuplicateDir   = errors.New("duplicate directory")
+	ErrRecursionLimit = errors.New("exceeds recursion limit")
+)
+
+// NormalizeDir 目录url归一化, 去掉query与fragment, 并保证以"/"结尾. 例如 HTTP://a.com:80/b 与 http://a.com/b/ 为同一个目录
+func NormalizeDir(u string) string {
+	parsed, err := url.Parse(NormalizeURL(u))
+	if err != nil {
+		return u
+	}
+	parsed.RawQuery = ""
+	parsed.ForceQuery = false
+	if !strings.HasSuffix(parsed.Path, "/") {
+		parsed.Path += "/"
+	}
+	return parsed.String()
+}
+
+func NewDirTree(limit int) *DirTree {
+	return &DirTree{
+		limit: limit,
+		nodes: make(map[string]*DirNode),
+	}
+}
+
+// DirTree 所有pool共享的递归管理, 对目录去重, 限制递归任务的总数, 并记录目录之间的父子关系
+type DirTree struct {
+	limit      int
+	recursions int
+	roots      []*DirNode
+	nodes      map[string]*DirNode
+	locker     sync.Mutex
+}
+
+type DirNode struct {
+	URL      string
+	Status   int
+	Title    string
+	Children []*DirNode
+}
+
+// Add 添加一个目录, bl为nil时表示输入的目标, 否则为递归发现的目录, 受到递归总数的限制.
+// 父节点为树中已经存在的最近的上级目录, 不存在时作为新的根节点
+func (t *DirTree) Add(u string, bl *Baseline) error {
+	dir := NormalizeDir(u)
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	if _, ok := t.nodes[dir]; ok {
+		return ErrDuplicateDir
+	}
+	if bl != nil {
+		if t.limit > 0 && t.recursions >= t.limit {
+			return ErrRecursionLimit
+		}
+		t.recursions++
+	}
+
+	node := &DirNode{URL: dir}
+	if bl != nil {
+		node.Status = bl.Status
+		node.Title = bl.Title
+	}
+	t.nodes[dir] = node
+	if parent := t.parent(dir); parent != nil {
+		parent.Children = append(parent.Children, node)
+	} else {
+		t.roots = append(t.roots, node)
+	}
+	return nil
+}
+
+func (t *DirTree) parent(dir string) *DirNode {
+	parsed, err := url.Parse(dir)
+	if err != nil {
+		return nil
+	}
+	p := strings.TrimSuffix(parsed.Path, "/")
+	for p != "" {
+		p = p[:strings.LastIndex(p, "/")]
+		parsed.Path = p + "/"
+		if node, ok := t.nodes[parsed.String()]; ok {
+			return node
+		}
+	}
+	return nil
+}
+
+// Recursions 递归发现的目录数量
+func (t *DirTree) Recursions() int {
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	return t.recursions
+}
+
+// String 以目录树的形式输出, 子目录只显示相对于父目录的路径
+func (t *DirTree) String() string {
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	var s strings.Builder
+	for _, root := range t.roots {
+		s.WriteString(root.URL + "\n")
+		root.write(&s, "")
+	}
+	return s.String()
+}
+
+func (node *DirNode) write(s *strings.Builder, prefix string) {
+	for i, child := range node.Children {
+		branch, next := "├── ", "│   "
+		if i == len(node.Children)-1 {
+			branch, next = "└── ", "    "
+		}
+		s.WriteString(prefix + branch + strings.TrimPrefix(child.URL, node.URL))
+		if child.Status != 0 {
+			s.WriteString(" [" + strconv.Itoa(child.Status) + "]")
+		}
+		if child.Title != "" {
+			s.WriteString(" " + child.Title)
+		}
+		s.WriteString("\n")
+		child.write(s, prefix+next)
+	}
+}
@@ -0,0 +1,126 @@
+package pkg
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	harvestParamRegexp = regexp.MustCompile(`[?&]([A-Za-z_][\w\-]*)=`)
+	harvestNameRegexp  = regexp.MustCompile(`(?i)\b(?:name|id)\s*=\s*["']([\w\-]+)["']`)
+	harvestPathRegexp  = regexp.MustCompile(`["'](/?[\w\-]+(?:/[\w\-.]+)+/?)["']`)
+	harvestJsRegexp    = regexp.MustCompile(`\b(?:var|let|const|function)\s+([A-Za-z_$][\w$]*)`)
+	harvestTagRegexp   = regexp.MustCompile(`(?s)<script.*?</script>|<style.*?</style>|<[^>]+>`)
+	harvestWordRegexp  = regexp.MustCompile(`[A-Za-z][\w\-]*`)
+	harvestCharset     = regexp.MustCompile(`^[\w\-.]+$`)
+
+	// HarvestStopWords 高频但几乎不会是路径的单词
+	HarvestStopWords = map[string]bool{
+		"the": true, "and": true, "for": true, "with": true, "this": true, "that": true, "from": true, "you": true,
+		"your": true, "are": true, "not": true, "http": true, "https": true, "www": true, "com": true, "html": true,
+		"function": true, "return": true, "var": true, "let": true, "const": true, "true": true, "false": true,
+		"null": true, "undefined": true, "typeof": true, "window": true, "document": true, "div": true, "span": true,
+	}
+)
+
+func NewHarvester(min, max, limit int) *Harvester {
+	return &Harvester{
+		Min:   min,
+		Max:   max,
+		Limit: limit,
+		words: make(map[string]struct{}),
+	}
+}
+
+// Harvester 类似CeWL, 从有效的响应中收集路径片段, 参数名, js变量名, 标题与正文中的单词, 作为新的字典.
+// 所有pool共享, 收集到的单词全局去重, 总数不超过Limit
+type Harvester struct {
+	Min      int
+	Max      int
+	Limit    int // 收集单词的总数上限, 为0时不限制
+	PerPage  int // 每个响应最多收集的单词数, 为0时不限制
+	words    map[string]struct{}
+	harvests []string
+	locker   sync.Mutex
+}
+
+// Harvest 返回该响应中新出现的单词
+func (h *Harvester) Harvest(bl *Baseline) []string {
+	var candidates []string
+	if bl.Url != nil {
+		candidates = append(candidates, strings.Split(bl.Url.Path, "/")...)
+	}
+	for _, u := range bl.URLs {
+		candidates = append(candidates, harvestSegments(u)...)
+	}
+	body := string(bl.Body)
+	for _, m := range harvestPathRegexp.FindAllStringSubmatch(body, -1) {
+		candidates = append(candidates, harvestSegments(m[1])...)
+	}
+	candidates = append(candidates, submatches(harvestParamRegexp, body)...)
+	candidates = append(candidates, submatches(harvestNameRegexp, body)...)
+	candidates = append(candidates, submatches(harvestJsRegexp, body)...)
+	candidates = append(candidates, harvestWordRegexp.FindAllString(bl.Title, -1)...)
+	candidates = append(candidates, harvestWordRegexp.FindAllString(harvestTagRegexp.ReplaceAllString(body, " "), -1)...)
+
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	var harvested []string
+	for _, w := range candidates {
+		if h.Limit > 0 && len(h.harvests) >= h.Limit || h.PerPage > 0 && len(harvested) >= h.PerPage {
+			break
+		}
+		if !h.valid(w) {
+			continue
+		}
+		if _, ok := h.words[w]; ok {
+			continue
+		}
+		h.words[w] = struct{}{}
+		h.harvests = append(h.harvests, w)
+		harvested = append(harvested, w)
+	}
+	return harvested
+}
+
+func (h *Harvester) valid(w string) bool {
+	if len(w) < h.Min || h.Max > 0 && len(w) > h.Max {
+		return false
+	}
+	if !harvestCharset.MatchString(w) || strings.Trim(w, "0123456789.-_") == "" {
+		return false
+	}
+	return !HarvestStopWords[strings.ToLower(w)]
+}
+
+// Words 按收集顺序返回所有单词
+func (h *Harvester) Words() []string {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	return append([]string{}, h.harvests...)
+}
+
+func harvestSegments(u string) []string {
+	if i := strings.IndexAny(u, "?#"); i != -1 {
+		u = u[:i]
+	}
+	if i := strings.Index(u, "://"); i != -1 {
+		// 去掉scheme与host
+		u = u[i+3:]
+		if j := strings.Index(u, "/"); j != -1 {
+			u = u[j:]
+		} else {
+			return nil
+		}
+	}
+	return strings.Split(u, "/")
+}
+
+func submatches(reg *regexp.Regexp, s string) []string {
+	var ss []string
+	for _, m := range reg.FindAllStringSubmatch(s, -1) {
+		ss = append(ss, m[1])
+	}
+	return ss
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"github.com/valyala/fasthttp"
+	"net"
 	"net/http"
 	"time"
 )
@@ -82,6 +83,20 @@ func (c *Client) TransToCheck() {
 	}
 }
 
+// Bind 所有的连接都发往指定的地址, 请求中的域名只用于Host与SNI, 用于host模式的vhost爆破
+func (c *Client) Bind(addr string) {
+	if c.fastClient != nil {
+		c.fastClient.Dial = func(string) (net.Conn, error) {
+			return fasthttp.DialTimeout(addr, c.timeout)
+		}
+	} else if c.standardClient != nil {
+		dialer := &net.Dialer{Timeout: c.timeout}
+		c.standardClient.Transport.(*http.Transport).DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+	}
+}
+
 func (c *Client) FastDo(ctx context.Context, req *fasthttp.Request) (*fasthttp.Response, error) {
 	resp := fasthttp.AcquireResponse()
 	err := c.fastClient.Do(req, resp)
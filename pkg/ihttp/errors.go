@@ -0,0 +1,103 @@
+package ihttp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/valyala/fasthttp"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// 请求错误的分类
+const (
+	ErrClassDNS          = "dns"
+	ErrClassRefused      = "refused"
+	ErrClassReset        = "reset"
+	ErrClassTLS          = "tls"
+	ErrClassTimeout      = "timeout"
+	ErrClassBodyTooLarge = "body-too-large"
+	ErrClassProxy        = "proxy"
+	ErrClassOther        = "other"
+)
+
+var ErrClasses = []string{ErrClassDNS, ErrClassRefused, ErrClassReset, ErrClassTLS, ErrClassTimeout, ErrClassBodyTooLarge, ErrClassProxy, ErrClassOther}
+
+// 代理与socks拨号失败时的错误信息, 只匹配完整的短语, 避免url或host中包含proxy的目标被误判
+var proxyPhrases = []string{"proxyconnect", "socks connect", "proxy: socks5", "could not connect to proxy"}
+
+// ClassifyError 将fasthttp与net/http返回的错误归类. 优先通过错误类型判断, fasthttp的部分错误只能通过错误信息判断
+func ClassifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+	if err == fasthttp.ErrBodyTooLarge {
+		return ErrClassBodyTooLarge
+	}
+
+	// 代理的错误中通常还包含了底层的错误, 需要最先判断
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "proxyconnect" {
+		return ErrClassProxy
+	}
+	// net/http的错误信息中包含完整的url, 只使用底层的错误信息
+	msg := err.Error()
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && urlErr.Err != nil {
+		msg = urlErr.Err.Error()
+	}
+	msg = strings.ToLower(msg)
+	for _, phrase := range proxyPhrases {
+		if strings.Contains(msg, phrase) {
+			return ErrClassProxy
+		}
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrClassDNS
+	}
+	var recordErr tls.RecordHeaderError
+	var certErr x509.UnknownAuthorityError
+	var hostErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &recordErr) || errors.As(err, &certErr) || errors.As(err, &hostErr) || errors.As(err, &invalidErr) {
+		return ErrClassTLS
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrClassRefused
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrClassReset
+	}
+	if err == fasthttp.ErrTimeout || err == fasthttp.ErrDialTimeout || err == fasthttp.ErrTLSHandshakeTimeout ||
+		errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrClassTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrClassTimeout
+	}
+
+	// 最后通过错误信息中的关键字兜底
+	switch {
+	case strings.Contains(msg, "no such host"):
+		return ErrClassDNS
+	case strings.Contains(msg, "connection refused"):
+		return ErrClassRefused
+	case strings.Contains(msg, "connection reset") || strings.Contains(msg, "broken pipe") || strings.Contains(msg, "server closed connection") || strings.Contains(msg, "eof"):
+		return ErrClassReset
+	case strings.Contains(msg, "tls") || strings.Contains(msg, "x509") || strings.Contains(msg, "certificate"):
+		return ErrClassTLS
+	case strings.Contains(msg, "timeout") || strings.Contains(msg, "timed out") || strings.Contains(msg, "deadline exceeded"):
+		return ErrClassTimeout
+	case strings.Contains(msg, "body size exceeds"):
+		return ErrClassBodyTooLarge
+	}
+	return ErrClassOther
+}
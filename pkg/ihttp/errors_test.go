@@ -0,0 +1,38 @@
+package ihttp
+
+import (
+	"errors"
+	"fmt"
+	"github.com/valyala/fasthttp"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, ""},
+		{"body too large", fasthttp.ErrBodyTooLarge, ErrClassBodyTooLarge},
+		{"proxyconnect", &url.Error{Op: "Get", URL: "http://example.com/", Err: &net.OpError{Op: "proxyconnect", Net: "tcp", Err: syscall.ECONNREFUSED}}, ErrClassProxy},
+		{"socks", errors.New("socks connect tcp 127.0.0.1:1080->example.com:80: unknown error general SOCKS server failure"), ErrClassProxy},
+		{"proxy in host", &url.Error{Op: "Get", URL: "http://proxy.example.com/", Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}, ErrClassRefused},
+		{"proxy in path", &url.Error{Op: "Get", URL: "http://example.com/proxy/socks", Err: syscall.ECONNRESET}, ErrClassReset},
+		{"proxy in message", fmt.Errorf("dial proxy.example.com:80: %w", os.ErrDeadlineExceeded), ErrClassTimeout},
+		{"dns", &net.DNSError{Err: "no such host", Name: "example.invalid"}, ErrClassDNS},
+		{"timeout", fasthttp.ErrDialTimeout, ErrClassTimeout},
+		{"deadline", &url.Error{Op: "Get", URL: "http://example.com/", Err: os.ErrDeadlineExceeded}, ErrClassTimeout},
+		{"fallback reset", errors.New("the server closed connection before returning the first response byte"), ErrClassReset},
+		{"other", errors.New("unexpected"), ErrClassOther},
+	}
+	for _, c := range cases {
+		if got := ClassifyError(c.err); got != c.want {
+			t.Errorf("%s: ClassifyError(%v) = %q, want %q", c.name, c.err, got, c.want)
+		}
+	}
+}
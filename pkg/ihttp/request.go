@@ -1,8 +1,12 @@
 package ihttp
 
 import (
+	"bytes"
 	"github.com/valyala/fasthttp"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
 )
 
 func BuildPathRequest(clientType int, base, path string) (*Request, error) {
@@ -16,16 +20,30 @@ func BuildPathRequest(clientType int, base, path string) (*Request, error) {
 	}
 }
 
+// BuildHostRequest 将base中的host替换为待爆破的域名, 保留端口与路径. 需要配合Client.Bind将连接发往base中的ip,
+// 这样Host与SNI都是正确的域名
 func BuildHostRequest(clientType int, base, host string) (*Request, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := net.SplitHostPort(host); err == nil || u.Port() == "" {
+		u.Host = host
+	} else {
+		u.Host = host + ":" + u.Port()
+	}
+
 	if clientType == FAST {
 		req := fasthttp.AcquireRequest()
-		req.SetRequestURI(base)
-		req.SetHost(host)
+		req.SetRequestURI(u.String())
 		return &Request{FastRequest: req, ClientType: FAST}, nil
 	} else {
-		req, err := http.NewRequest("GET", base, nil)
-		req.Host = host
-		return &Request{StandardRequest: req, ClientType: STANDARD}, err
+		req, err := http.NewRequest("GET", u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Host = u.Host
+		return &Request{StandardRequest: req, ClientType: STANDARD}, nil
 	}
 }
 
@@ -55,6 +73,25 @@ func (r *Request) SetHeader(key, value string) {
 	}
 }
 
+func (r *Request) SetMethod(method string) {
+	if r.StandardRequest != nil {
+		r.StandardRequest.Method = method
+	} else if r.FastRequest != nil {
+		r.FastRequest.Header.SetMethod(method)
+	}
+}
+
+func (r *Request) SetBody(contentType string, body []byte) {
+	if r.StandardRequest != nil {
+		r.StandardRequest.Body = ioutil.NopCloser(bytes.NewReader(body))
+		r.StandardRequest.ContentLength = int64(len(body))
+		r.StandardRequest.Header.Set("Content-Type", contentType)
+	} else if r.FastRequest != nil {
+		r.FastRequest.SetBody(body)
+		r.FastRequest.Header.SetContentType(contentType)
+	}
+}
+
 func (r *Request) URI() string {
 	if r.FastRequest != nil {
 		return r.FastRequest.URI().String()
@@ -2,6 +2,7 @@ package ihttp
 
 import (
 	"bytes"
+	"crypto/x509"
 	"github.com/chainreactors/logs"
 	"github.com/valyala/fasthttp"
 	"io"
@@ -114,3 +115,11 @@ func (r *Response) GetHeader(key string) string {
 		return ""
 	}
 }
+
+// PeerCertificate 服务端返回的证书, fasthttp无法获取tls连接的状态, 只有标准库的client可以获取
+func (r *Response) PeerCertificate() *x509.Certificate {
+	if r.StandardResponse != nil && r.StandardResponse.TLS != nil && len(r.StandardResponse.TLS.PeerCertificates) > 0 {
+		return r.StandardResponse.TLS.PeerCertificates[0]
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package pkg
+
+import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	jsScriptRegexp    = regexp.MustCompile(`(?i)<script[^>]+src\s*=\s*["']?([^"'\s>]+)`)
+	jsImportRegexp    = regexp.MustCompile(`(?:import\s*\(|import\s+[^"'\x60;]*?from\s*|require\s*\()\s*["'\x60]([^"'\x60\s]+\.js)["'\x60]`)
+	jsSourceMapRegexp = regexp.MustCompile(`//[#@]\s*sourceMappingURL\s*=\s*(\S+)`)
+	jsPublicRegexp    = regexp.MustCompile(`\w+\.p\s*=\s*["']([^"']*)["']`)
+	// webpack runtime中的chunk文件名, 例如 "static/js/" + ({1:"about"}[e]||e) + "." + {1:"3f2a9c"}[e] + ".chunk.js"
+	jsChunkRegexp    = regexp.MustCompile(`["']([\w\-./]*)["']\s*\+\s*(?:\(\s*(\{[^{}]*\})\s*\[\w+\]\s*\|\|\s*\w+\s*\)|\w+)\s*\+\s*["']([\w\-.]*)["']\s*\+\s*(\{[^{}]*\})\s*\[\w+\]\s*\+\s*["']([\w\-.]*\.js)["']`)
+	jsMapEntryRegexp = regexp.MustCompile(`(?:"([^"]+)"|'([^']+)'|([\w$]+))\s*:\s*["']([^"']+)["']`)
+	jsAPIRegexp      = regexp.MustCompile(`["'\x60](/[\w\-./]*[A-Za-z][\w\-./]*(?:\?[^"'\x60\s]*)?)["'\x60]`)
+	jsRelAPIRegexp   = regexp.MustCompile(`["'\x60]((?:api|apis|v\d|rest|service|services|admin|auth|gateway)/[\w\-./]+(?:\?[^"'\x60\s]*)?)["'\x60]`)
+	jsTemplateRegexp = regexp.MustCompile("`(/[\\w\\-./]+)\\$\\{")
+	jsBaseURLRegexp  = regexp.MustCompile(`(?i)(?:base_?url|base_?api|api_?base|api_?url|api_?root|api_?prefix|api_?host|server_?url)["']?\s*[:=]\s*["'\x60]([^"'\x60\s]+)["'\x60]`)
+	jsQueryRegexp    = regexp.MustCompile(`[?&]([A-Za-z_][\w\-]*)=`)
+	jsParamsRegexp   = regexp.MustCompile(`(?:params|data|query)\s*:\s*\{([^{}]*)\}`)
+	jsKeyRegexp      = regexp.MustCompile(`([A-Za-z_$][\w$]*)\s*:`)
+)
+
+// JsAnalysis js与sourcemap的分析结果, 路径均为站点根目录下的绝对路径, 其他站点的url只记录在BaseURLs中
+type JsAnalysis struct {
+	Scripts  []string // 引用的js, 以及js的sourcemap
+	Chunks   []string // webpack runtime中声明的chunk文件
+	APIs     []string
+	BaseURLs []string
+	Params   []string
+	Sources  []string // sourcemap还原的源文件名
+}
+
+// ScriptURLs html中引用的js, 未经过处理的原始url
+func ScriptURLs(body []byte) []string {
+	var scripts []string
+	for _, m := range jsScriptRegexp.FindAllSubmatch(body, -1) {
+		scripts = append(scripts, string(m[1]))
+	}
+	return RemoveDuplication(scripts)
+}
+
+// AnalyzeJs 分析js的内容, p为该js的路径, 用于拼接相对路径的import, sourcemap与chunk
+func AnalyzeJs(p string, body []byte) *JsAnalysis {
+	a := &JsAnalysis{}
+	content := string(body)
+	dir := p[:strings.LastIndex(p, "/")+1]
+	for _, m := range jsImportRegexp.FindAllStringSubmatch(content, -1) {
+		a.Scripts = append(a.Scripts, jsResolve(dir, m[1]))
+	}
+	if m := jsSourceMapRegexp.FindStringSubmatch(content); m != nil {
+		if !strings.HasPrefix(m[1], "data:") {
+			a.Scripts = append(a.Scripts, jsResolve(dir, m[1]))
+		}
+	} else if strings.HasSuffix(p, ".js") {
+		// 没有声明sourcemap时, 猜测同名的.map
+		a.Scripts = append(a.Scripts, p+".map")
+	}
+	a.Chunks = webpackChunks(p, content)
+	a.analyze(content)
+	return a.dedup()
+}
+
+// AnalyzeSourceMap 从sourcemap中还原源文件名, 并分析源文件中的api
+func AnalyzeSourceMap(body []byte) *JsAnalysis {
+	var sm struct {
+		Sources        []string `json:"sources"`
+		SourcesContent []string `json:"sourcesContent"`
+	}
+	if err := json.Unmarshal(body, &sm); err != nil {
+		return nil
+	}
+	a := &JsAnalysis{}
+	for i, source := range sm.Sources {
+		source = normalizeSource(source)
+		if source == "" {
+			continue
+		}
+		a.Sources = append(a.Sources, source)
+		if i < len(sm.SourcesContent) {
+			a.analyze(sm.SourcesContent[i])
+		}
+	}
+	return a.dedup()
+}
+
+func (a *JsAnalysis) analyze(content string) {
+	var apis []string
+	for _, m := range jsAPIRegexp.FindAllStringSubmatch(content, -1) {
+		apis = append(apis, m[1])
+	}
+	for _, m := range jsRelAPIRegexp.FindAllStringSubmatch(content, -1) {
+		apis = append(apis, "/"+m[1])
+	}
+	for _, m := range jsTemplateRegexp.FindAllStringSubmatch(content, -1) {
+		apis = append(apis, m[1])
+	}
+	for _, api := range apis {
+		if validAPI(api) {
+			a.APIs = append(a.APIs, api)
+		}
+	}
+
+	for _, m := range jsBaseURLRegexp.FindAllStringSubmatch(content, -1) {
+		a.BaseURLs = append(a.BaseURLs, m[1])
+	}
+	for _, m := range jsQueryRegexp.FindAllStringSubmatch(content, -1) {
+		a.Params = append(a.Params, m[1])
+	}
+	for _, m := range jsParamsRegexp.FindAllStringSubmatch(content, -1) {
+		for _, k := range jsKeyRegexp.FindAllStringSubmatch(m[1], -1) {
+			a.Params = append(a.Params, k[1])
+		}
+	}
+}
+
+// WithBaseURLs 将api与js中声明的相对路径的baseURL拼接, 例如 /prod-api + /system/user/list
+func (a *JsAnalysis) WithBaseURLs() []string {
+	apis := a.APIs
+	for _, base := range a.BaseURLs {
+		if !strings.HasPrefix(base, "/") || strings.HasPrefix(base, "//") {
+			continue
+		}
+		base = strings.TrimSuffix(base, "/")
+		apis = append(apis, base+"/")
+		for _, api := range a.APIs {
+			if api != base && !strings.HasPrefix(api, base+"/") {
+				apis = append(apis, base+api)
+			}
+		}
+	}
+	return RemoveDuplication(apis)
+}
+
+func (a *JsAnalysis) dedup() *JsAnalysis {
+	a.Scripts = RemoveDuplication(a.Scripts)
+	a.Chunks = RemoveDuplication(a.Chunks)
+	a.APIs = RemoveDuplication(a.APIs)
+	a.BaseURLs = RemoveDuplication(a.BaseURLs)
+	a.Params = RemoveDuplication(a.Params)
+	a.Sources = RemoveDuplication(a.Sources)
+	return a
+}
+
+// webpackChunks 根据webpack runtime中chunk id与hash的映射枚举所有chunk文件
+func webpackChunks(p, content string) []string {
+	var chunks []string
+	for _, m := range jsChunkRegexp.FindAllStringSubmatch(content, -1) {
+		prefix, names, sep, hashes, suffix := m[1], jsObject(m[2]), m[3], jsObject(m[4]), m[5]
+		base := "/"
+		if pub := jsPublicRegexp.FindStringSubmatch(content); pub != nil && strings.HasPrefix(pub[1], "/") {
+			base = pub[1]
+		} else if i := strings.Index(p, "/"+prefix); prefix != "" && i != -1 {
+			// 没有publicPath时, 根据当前js的路径推断
+			base = p[:i+1]
+		}
+		if !strings.HasSuffix(base, "/") {
+			base += "/"
+		}
+		for id, hash := range hashes {
+			name := id
+			if n, ok := names[id]; ok {
+				name = n
+			}
+			chunks = append(chunks, base+strings.TrimPrefix(prefix, "/")+name+sep+hash+suffix)
+		}
+	}
+	return chunks
+}
+
+func jsObject(s string) map[string]string {
+	obj := make(map[string]string)
+	for _, m := range jsMapEntryRegexp.FindAllStringSubmatch(s, -1) {
+		obj[m[1]+m[2]+m[3]] = m[4]
+	}
+	return obj
+}
+
+func jsResolve(dir, u string) string {
+	if strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") || strings.HasPrefix(u, "/") {
+		return u
+	}
+	return path.Join(dir, u)
+}
+
+func validAPI(api string) bool {
+	if len(api) < 3 || len(api) > 256 || strings.HasPrefix(api, "//") || strings.Contains(api, "..") {
+		return false
+	}
+	p := api
+	if i := strings.Index(p, "?"); i != -1 {
+		p = p[:i]
+	}
+	ext := path.Ext(p)
+	for _, e := range BadExt {
+		if strings.EqualFold(e, ext) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeSource 去掉sourcemap中的webpack://等前缀, 忽略node_modules与webpack自身的源文件
+func normalizeSource(source string) string {
+	if i := strings.Index(source, "://"); i != -1 {
+		source = source[i+3:]
+		if j := strings.Index(source, "/"); j != -1 {
+			// webpack://<project>/src/main.js
+			source = source[j+1:]
+		}
+	}
+	source = strings.Replace(source, "~/", "node_modules/", 1)
+	for strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../") || strings.HasPrefix(source, "/") {
+		source = strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(source, "./"), "../"), "/")
+	}
+	if i := strings.Index(source, "?"); i != -1 {
+		source = source[:i]
+	}
+	if source == "" || strings.HasPrefix(source, "node_modules/") || strings.HasPrefix(source, "(webpack)") || strings.HasPrefix(source, "webpack/") {
+		return ""
+	}
+	return source
+}
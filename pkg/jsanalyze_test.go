@@ -0,0 +1,184 @@
+package pkg
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func sortedStrings(s []string) []string {
+	s = append([]string(nil), s...)
+	sort.Strings(s)
+	return s
+}
+
+func TestScriptURLs(t *testing.T) {
+	body := []byte(`<html><head>
+<script src="/static/js/app.js"></script>
+<SCRIPT type="module" SRC='main.js?v=1'></SCRIPT>
+<script src=//cdn.example.com/lib.js></script>
+<script>var a = "<script src=inline.js>"</script>
+<script src="/static/js/app.js"></script>
+<script src="">
+<script src="/unclosed.js`)
+	want := []string{"/static/js/app.js", "main.js?v=1", "//cdn.example.com/lib.js", "inline.js", "/unclosed.js"}
+	if got := ScriptURLs(body); !reflect.DeepEqual(got, want) {
+		t.Errorf("ScriptURLs = %q, want %q", got, want)
+	}
+	if got := ScriptURLs(nil); len(got) != 0 {
+		t.Errorf("ScriptURLs(nil) = %q", got)
+	}
+}
+
+var jsFixture = []byte(`import a from "./a.js";
+import("../lib/b.js");
+const c = require('https://cdn.example.com/c.js');
+const d = require("httpClient.js");
+var baseURL = "/prod-api";
+axios.get("/system/user/list?pageNum=1&pageSize=10");
+axios.post("api/login", {params: {username: u, password: p}});
+fetch(` + "`/user/${id}/profile`" + `);
+var img = "/static/img/logo.png", x = "/a", y = "//cdn.example.com/x", z = "/../etc/passwd";
+//# sourceMappingURL=app.js.map`)
+
+func TestAnalyzeJs(t *testing.T) {
+	a := AnalyzeJs("/static/js/app.js", jsFixture)
+	cases := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"scripts", a.Scripts, []string{"/static/js/a.js", "/static/lib/b.js", "https://cdn.example.com/c.js", "/static/js/httpClient.js", "/static/js/app.js.map"}},
+		{"apis", a.APIs, []string{"/prod-api", "/system/user/list?pageNum=1&pageSize=10", "/api/login", "/user/"}},
+		{"base", a.BaseURLs, []string{"/prod-api"}},
+		{"params", a.Params, []string{"pageNum", "pageSize", "username", "password"}},
+	}
+	for _, c := range cases {
+		if !reflect.DeepEqual(sortedStrings(c.got), sortedStrings(c.want)) {
+			t.Errorf("%s = %q, want %q", c.name, c.got, c.want)
+		}
+	}
+
+	want := []string{
+		"/prod-api", "/system/user/list?pageNum=1&pageSize=10", "/api/login", "/user/",
+		"/prod-api/", "/prod-api/system/user/list?pageNum=1&pageSize=10", "/prod-api/api/login", "/prod-api/user/",
+	}
+	if got := a.WithBaseURLs(); !reflect.DeepEqual(sortedStrings(got), sortedStrings(want)) {
+		t.Errorf("WithBaseURLs = %q, want %q", got, want)
+	}
+}
+
+func TestAnalyzeJsSourceMap(t *testing.T) {
+	cases := []struct {
+		path string
+		body string
+		want []string
+	}{
+		{"/js/app.js", "", []string{"/js/app.js.map"}},
+		{"/js/app.js", "//@ sourceMappingURL=/maps/app.map", []string{"/maps/app.map"}},
+		{"/js/app.js", "//# sourceMappingURL=data:application/json;base64,e30=", nil},
+		{"/js/app", "", nil},
+	}
+	for _, c := range cases {
+		if got := AnalyzeJs(c.path, []byte(c.body)).Scripts; !reflect.DeepEqual(got, c.want) && (len(got) != 0 || len(c.want) != 0) {
+			t.Errorf("AnalyzeJs(%s, %q).Scripts = %q, want %q", c.path, c.body, got, c.want)
+		}
+	}
+}
+
+func TestWebpackChunks(t *testing.T) {
+	// webpack 4 runtime
+	runtime := `__webpack_require__.p = "/assets/";
+script.src = __webpack_require__.p + "static/js/" + ({"1":"about","2":"user"}[chunkId]||chunkId) + "." + {"1":"3f2a9c","2":"b81e00","3":"77aa01"}[chunkId] + ".chunk.js"`
+	want := []string{"/assets/static/js/about.3f2a9c.chunk.js", "/assets/static/js/user.b81e00.chunk.js", "/assets/static/js/3.77aa01.chunk.js"}
+	if got := AnalyzeJs("/assets/static/js/runtime.js", []byte(runtime)).Chunks; !reflect.DeepEqual(sortedStrings(got), sortedStrings(want)) {
+		t.Errorf("chunks = %q, want %q", got, want)
+	}
+
+	// 没有publicPath时根据js路径推断
+	runtime = `return "js/" + e + "." + {12:'aa11','chunk-vendors':'bb22'}[e] + ".js"`
+	want = []string{"/sub/js/12.aa11.js", "/sub/js/chunk-vendors.bb22.js"}
+	if got := AnalyzeJs("/sub/js/runtime.js", []byte(runtime)).Chunks; !reflect.DeepEqual(sortedStrings(got), sortedStrings(want)) {
+		t.Errorf("chunks = %q, want %q", got, want)
+	}
+
+	// 截断在映射表中间
+	if got := AnalyzeJs("/a.js", []byte(`"js/" + e + "." + {1:"aa",2:"b`)).Chunks; len(got) != 0 {
+		t.Errorf("truncated chunks = %q", got)
+	}
+}
+
+func TestAnalyzeSourceMap(t *testing.T) {
+	body := []byte(`{"version":3,"sources":[
+"webpack://my-app/./src/api/user.js",
+"webpack:///./src/views/Login.vue?1a2b",
+"webpack:///webpack/bootstrap",
+"webpack:///(webpack)/buildin/global.js",
+"webpack:///./node_modules/axios/index.js",
+"~/lodash/lodash.js",
+"../../src/utils/request.js",
+"",
+"/"],
+"sourcesContent":["export function list(){return request({url:'/system/user/list',params:{page:1}})}", null]}`)
+	a := AnalyzeSourceMap(body)
+	if a == nil {
+		t.Fatal("AnalyzeSourceMap returned nil")
+	}
+	if want := []string{"src/api/user.js", "src/views/Login.vue", "src/utils/request.js"}; !reflect.DeepEqual(a.Sources, want) {
+		t.Errorf("sources = %q, want %q", a.Sources, want)
+	}
+	if want := []string{"/system/user/list"}; !reflect.DeepEqual(a.APIs, want) {
+		t.Errorf("apis = %q, want %q", a.APIs, want)
+	}
+	if want := []string{"page"}; !reflect.DeepEqual(a.Params, want) {
+		t.Errorf("params = %q, want %q", a.Params, want)
+	}
+
+	for _, malformed := range []string{"", "not json", `{"sources":"a.js"}`, `{"sources":[1,2]}`, `[]`} {
+		if a := AnalyzeSourceMap([]byte(malformed)); a != nil {
+			t.Errorf("AnalyzeSourceMap(%q) = %+v, want nil", malformed, a)
+		}
+	}
+	// 截断的sourcemap不是合法的json
+	for i := 0; i < len(body); i++ {
+		if a := AnalyzeSourceMap(body[:i]); a != nil {
+			t.Errorf("truncated at %d returned %+v", i, a)
+		}
+	}
+}
+
+// TestAnalyzeJsTruncated 截断与损坏的js不能panic, 也不能返回不合法的api
+func TestAnalyzeJsTruncated(t *testing.T) {
+	for i := 0; i < len(jsFixture); i++ {
+		for _, p := range []string{"/static/js/app.js", "app.js", "", "/"} {
+			a := AnalyzeJs(p, jsFixture[:i])
+			for _, api := range a.WithBaseURLs() {
+				if !strings.HasPrefix(api, "/") || strings.Contains(api, "..") {
+					t.Fatalf("truncated at %d returned invalid api %q", i, api)
+				}
+			}
+		}
+	}
+	AnalyzeJs("/a.js", []byte("\x00\xff`/${\"'"+strings.Repeat("{", 10000)))
+}
+
+func TestValidAPI(t *testing.T) {
+	cases := map[string]bool{
+		"/api/user":                    true,
+		"/api/user?id=1":               true,
+		"/a":                           false,
+		"/ab":                          true,
+		"/":                            false,
+		"//cdn.example.com/a":          false,
+		"/static/../etc/passwd":        false,
+		"/static/app.js":               false,
+		"/static/logo.PNG?v=1":         false,
+		"/" + strings.Repeat("a", 300): false,
+	}
+	for api, want := range cases {
+		if got := validAPI(api); got != want {
+			t.Errorf("validAPI(%s) = %v, want %v", api, got, want)
+		}
+	}
+}
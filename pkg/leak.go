@@ -0,0 +1,530 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"math"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+)
+
+// Leak 从版本控制与元数据文件中解析出的文件列表, Paths均相对于Root
+type Leak struct {
+	Name  string
+	File  string // 解析的元数据文件
+	Root  string // 泄露所在的目录, 以"/"结尾
+	Paths []string
+}
+
+// LeakTriggers 命中这些目录下的任意路径时, 请求对应的元数据文件
+var LeakTriggers = map[string][]string{
+	".git/":    {".git/index", ".git/packed-refs", ".git/HEAD"},
+	".svn/":    {".svn/wc.db", ".svn/entries"},
+	".hg/":     {".hg/store/fncache", ".hg/dirstate"},
+	"WEB-INF/": {"WEB-INF/web.xml"},
+}
+
+type leakParser struct {
+	name  string
+	parse func([]byte) []string
+}
+
+// leakParsers 元数据文件相对于Root的路径与对应的解析函数
+var leakParsers = map[string]leakParser{
+	".git/index":        {"git", ParseGitIndex},
+	".git/packed-refs":  {"git", ParseGitRefs},
+	".git/HEAD":         {"git", ParseGitRefs},
+	".svn/wc.db":        {"svn", ParseSvnWcDB},
+	".svn/entries":      {"svn", ParseSvnEntries},
+	".hg/store/fncache": {"hg", ParseHgFncache},
+	".hg/dirstate":      {"hg", ParseHgDirstate},
+	".DS_Store":         {"ds_store", ParseDSStore},
+	"WEB-INF/web.xml":   {"web.xml", ParseWebXML},
+}
+
+// LeakFiles 路径位于.git/, .svn/, .hg/, WEB-INF/下时, 返回泄露所在的目录与需要请求的元数据文件
+func LeakFiles(p string) (string, []string) {
+	for dir, files := range LeakTriggers {
+		if i := strings.Index(p, "/"+dir); i != -1 {
+			return p[:i+1], files
+		} else if strings.HasPrefix(p, dir) {
+			return "/", files
+		}
+	}
+	return "", nil
+}
+
+// ParseLeak 路径为已知的元数据文件时, 解析出其中包含的文件路径
+func ParseLeak(p string, body []byte) *Leak {
+	for file, parser := range leakParsers {
+		if !strings.HasSuffix(p, "/"+file) {
+			continue
+		}
+		paths := parser.parse(body)
+		if len(paths) == 0 {
+			return nil
+		}
+		return &Leak{
+			Name:  parser.name,
+			File:  file,
+			Root:  strings.TrimSuffix(p, file),
+			Paths: RemoveDuplication(paths),
+		}
+	}
+	return nil
+}
+
+// ParseGitIndex 解析.git/index中的文件名, 支持v2, v3, v4. 响应被截断时返回已经解析的部分
+func ParseGitIndex(data []byte) []string {
+	if len(data) < 12 || string(data[:4]) != "DIRC" {
+		return nil
+	}
+	version := binary.BigEndian.Uint32(data[4:8])
+	if version < 2 || version > 4 {
+		return nil
+	}
+	count := binary.BigEndian.Uint32(data[8:12])
+	var names []string
+	var prev string
+	off := 12
+	for i := uint32(0); i < count; i++ {
+		start := off
+		if off+62 > len(data) {
+			break
+		}
+		flags := binary.BigEndian.Uint16(data[off+60 : off+62])
+		off += 62
+		if version >= 3 && flags&0x4000 != 0 {
+			// extended flags
+			off += 2
+		}
+		if off > len(data) {
+			break
+		}
+		var name string
+		if version == 4 {
+			// v4使用前缀压缩, 先读取需要从上一个文件名末尾删除的长度
+			strip, n := gitVarint(data[off:])
+			if n == 0 || strip > uint64(len(prev)) {
+				break
+			}
+			off += n
+			end := bytes.IndexByte(data[off:], 0)
+			if end == -1 {
+				break
+			}
+			name = prev[:len(prev)-int(strip)] + string(data[off:off+end])
+			off += end + 1
+		} else {
+			end := bytes.IndexByte(data[off:], 0)
+			if end == -1 {
+				break
+			}
+			name = string(data[off : off+end])
+			// 以1-8个NUL填充到8字节对齐
+			off = start + (off-start+end+8)&^7
+		}
+		prev = name
+		names = append(names, name)
+	}
+	return names
+}
+
+func gitVarint(data []byte) (uint64, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	c := data[0]
+	val := uint64(c & 127)
+	n := 1
+	for c&128 != 0 {
+		if n >= len(data) {
+			return 0, 0
+		}
+		c = data[n]
+		val = ((val + 1) << 7) + uint64(c&127)
+		n++
+	}
+	return val, n
+}
+
+// ParseGitRefs 解析packed-refs与HEAD中的引用, 返回对应的ref与reflog文件
+func ParseGitRefs(data []byte) []string {
+	var paths []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		var ref string
+		if strings.HasPrefix(line, "ref: ") {
+			ref = strings.TrimPrefix(line, "ref: ")
+		} else if fields := strings.Fields(line); len(fields) == 2 && len(fields[0]) == 40 && !strings.HasPrefix(line, "#") {
+			ref = fields[1]
+		}
+		if strings.HasPrefix(ref, "refs/") {
+			paths = append(paths, ".git/"+ref, ".git/logs/"+ref)
+		}
+	}
+	return paths
+}
+
+// ParseSvnEntries 解析svn 1.6及以下版本的.svn/entries, 文件同时返回text-base中的原始文件, 目录返回下一级的entries
+func ParseSvnEntries(data []byte) []string {
+	var paths []string
+	blocks := strings.Split(string(data), "\x0c\n")
+	for i, block := range blocks {
+		lines := strings.Split(block, "\n")
+		if i == 0 || len(lines) < 2 || lines[0] == "" {
+			// 第一个块为格式版本与当前目录
+			continue
+		}
+		name, kind := lines[0], lines[1]
+		switch kind {
+		case "file":
+			paths = append(paths, name, ".svn/text-base/"+name+".svn-base")
+		case "dir":
+			paths = append(paths, name+"/", name+"/.svn/entries")
+		}
+	}
+	return paths
+}
+
+var svnChecksumRegexp = regexp.MustCompile(`^\$sha1\$([0-9a-f]{40})$`)
+
+// ParseSvnWcDB 解析svn 1.7及以上版本的.svn/wc.db, 从NODES表中读取文件路径与pristine中的原始文件
+func ParseSvnWcDB(data []byte) []string {
+	var paths []string
+	for _, record := range sqliteRecords(data) {
+		// NODES: wc_id, local_relpath, op_depth, parent_relpath, repos_id, repos_path, revision, presence, moved_here, moved_to, kind, properties, depth, checksum
+		if len(record) < 11 {
+			continue
+		}
+		relpath, ok1 := record[1].(string)
+		presence, ok2 := record[7].(string)
+		kind, ok3 := record[10].(string)
+		if !ok1 || !ok2 || !ok3 || relpath == "" || presence != "normal" {
+			continue
+		}
+		switch kind {
+		case "file":
+			paths = append(paths, relpath)
+		case "dir":
+			paths = append(paths, relpath+"/")
+		default:
+			continue
+		}
+		if len(record) > 13 {
+			if checksum, ok := record[13].(string); ok {
+				if m := svnChecksumRegexp.FindStringSubmatch(checksum); m != nil {
+					paths = append(paths, ".svn/pristine/"+m[1][:2]+"/"+m[1]+".svn-base")
+				}
+			}
+		}
+	}
+	return paths
+}
+
+// sqliteRecords 遍历sqlite文件中所有table b-tree叶子页的记录, 只读取页内的部分, 不处理溢出页
+func sqliteRecords(data []byte) [][]interface{} {
+	if len(data) < 100 || !bytes.HasPrefix(data, []byte("SQLite format 3\x00")) {
+		return nil
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 {
+		return nil
+	}
+	usable := pageSize - int(data[20])
+	var records [][]interface{}
+	for start := 0; start+pageSize <= len(data); start += pageSize {
+		page := data[start : start+pageSize]
+		header := 0
+		if start == 0 {
+			header = 100
+		}
+		if page[header] != 0x0d {
+			continue
+		}
+		cells := int(binary.BigEndian.Uint16(page[header+3 : header+5]))
+		for i := 0; i < cells; i++ {
+			ptr := header + 8 + i*2
+			if ptr+2 > len(page) {
+				break
+			}
+			off := int(binary.BigEndian.Uint16(page[ptr : ptr+2]))
+			if off >= len(page) {
+				continue
+			}
+			payload, n1 := sqliteVarint(page[off:])
+			_, n2 := sqliteVarint(page[off+n1:])
+			off += n1 + n2
+			if n1 == 0 || n2 == 0 || payload > math.MaxInt32 {
+				// 超出sqlite长度上限的payload为损坏的数据
+				continue
+			}
+			local := sqliteLocal(int(payload), usable)
+			if off+local > len(page) {
+				continue
+			}
+			records = append(records, sqliteRecord(page[off:off+local]))
+		}
+	}
+	return records
+}
+
+func sqliteLocal(payload, usable int) int {
+	x := usable - 35
+	if payload <= x {
+		return payload
+	}
+	m := (usable-12)*32/255 - 23
+	k := m + (payload-m)%(usable-4)
+	if k <= x {
+		return k
+	}
+	return m
+}
+
+func sqliteRecord(data []byte) []interface{} {
+	size, n := sqliteVarint(data)
+	if n == 0 || size > uint64(len(data)) {
+		return nil
+	}
+	var types []uint64
+	for off := n; off < int(size); {
+		t, n := sqliteVarint(data[off:])
+		if n == 0 {
+			return nil
+		}
+		types = append(types, t)
+		off += n
+	}
+	var values []interface{}
+	off := int(size)
+	for _, t := range types {
+		var length int
+		switch {
+		case t >= 12:
+			if t-12 > uint64(len(data))*2 {
+				return values
+			}
+			length = int(t-12) / 2
+		case t >= 1 && t <= 4:
+			length = int(t)
+		case t == 5:
+			length = 6
+		case t == 6 || t == 7:
+			length = 8
+		}
+		if off+length > len(data) {
+			break
+		}
+		v := data[off : off+length]
+		switch {
+		case t >= 13 && t%2 == 1:
+			values = append(values, string(v))
+		case t >= 12:
+			values = append(values, v)
+		case t >= 1 && t <= 6:
+			var i int64
+			for _, b := range v {
+				i = i<<8 | int64(b)
+			}
+			values = append(values, i)
+		default:
+			values = append(values, nil)
+		}
+		off += length
+	}
+	return values
+}
+
+func sqliteVarint(data []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9 && i < len(data); i++ {
+		if i == 8 {
+			return v<<8 | uint64(data[i]), 9
+		}
+		v = v<<7 | uint64(data[i]&0x7f)
+		if data[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// ParseHgFncache 解析.hg/store/fncache, 每行为 data/<path>.i 或 data/<path>.d
+func ParseHgFncache(data []byte) []string {
+	var paths []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "data/") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "data/")
+		line = strings.TrimSuffix(strings.TrimSuffix(line, ".i"), ".d")
+		if line != "" {
+			paths = append(paths, line)
+		}
+	}
+	return paths
+}
+
+// ParseHgDirstate 解析v1格式的.hg/dirstate, 40字节的parents之后为 state(1) mode(4) size(4) mtime(4) length(4) name
+func ParseHgDirstate(data []byte) []string {
+	var paths []string
+	off := 40
+	for off+17 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[off+13 : off+17]))
+		off += 17
+		if length <= 0 || off+length > len(data) {
+			break
+		}
+		name := string(data[off : off+length])
+		if i := strings.IndexByte(name, 0); i != -1 {
+			// 复制或者重命名的文件, 0之后为来源文件
+			name = name[:i]
+		}
+		paths = append(paths, name)
+		off += length
+	}
+	return paths
+}
+
+// dsStoreTypes .DS_Store中记录的数据类型
+var dsStoreTypes = map[string]bool{
+	"long": true, "shor": true, "bool": true, "blob": true, "type": true, "ustr": true, "comp": true, "dutc": true,
+}
+
+// ParseDSStore 从.DS_Store中读取文件名. 每条记录为 length(4) utf-16be文件名 structure-id(4) type(4),
+// 不解析buddy allocator, 直接扫描所有满足该结构的记录. 没有后缀的文件名可能是目录, 同时返回其中的.DS_Store
+func ParseDSStore(data []byte) []string {
+	if len(data) < 36 || !bytes.Equal(data[4:8], []byte("Bud1")) {
+		return nil
+	}
+	var paths []string
+	seen := make(map[string]bool)
+	for off := 0; off+4 <= len(data); off++ {
+		length := int(binary.BigEndian.Uint32(data[off : off+4]))
+		end := off + 4 + length*2
+		if length == 0 || length > 255 || end+8 > len(data) {
+			continue
+		}
+		if !isDSStoreID(data[end:end+4]) || !dsStoreTypes[string(data[end+4:end+8])] {
+			continue
+		}
+		units := make([]uint16, length)
+		valid := true
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(data[off+4+i*2:])
+			if units[i] < 0x20 || units[i] == '/' {
+				valid = false
+				break
+			}
+		}
+		if !valid {
+			continue
+		}
+		name := string(utf16.Decode(units))
+		if name == "." || name == ".." || seen[name] {
+			continue
+		}
+		seen[name] = true
+		paths = append(paths, name)
+		if !strings.Contains(name, ".") {
+			paths = append(paths, name+"/.DS_Store")
+		}
+	}
+	return paths
+}
+
+func isDSStoreID(id []byte) bool {
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+type webXML struct {
+	Servlets []struct {
+		Class   string `xml:"servlet-class"`
+		JspFile string `xml:"jsp-file"`
+	} `xml:"servlet"`
+	ServletMappings []struct {
+		Patterns []string `xml:"url-pattern"`
+	} `xml:"servlet-mapping"`
+	Filters []struct {
+		Class string `xml:"filter-class"`
+	} `xml:"filter"`
+	FilterMappings []struct {
+		Patterns []string `xml:"url-pattern"`
+	} `xml:"filter-mapping"`
+	Listeners []struct {
+		Class string `xml:"listener-class"`
+	} `xml:"listener"`
+	ContextParams []struct {
+		Value string `xml:"param-value"`
+	} `xml:"context-param"`
+	WelcomeFiles []string `xml:"welcome-file-list>welcome-file"`
+	ErrorPages   []string `xml:"error-page>location"`
+}
+
+// ParseWebXML 解析WEB-INF/web.xml, 返回servlet与filter的映射路径, jsp, 配置文件, 以及WEB-INF/classes中的class文件
+func ParseWebXML(data []byte) []string {
+	var web webXML
+	if err := xml.Unmarshal(data, &web); err != nil {
+		return nil
+	}
+	var paths []string
+	addPattern := func(p string) {
+		p = strings.TrimPrefix(strings.TrimSuffix(p, "*"), "/")
+		if p != "" && !strings.Contains(p, "*") {
+			paths = append(paths, p)
+		}
+	}
+	addClass := func(c string) {
+		if c = strings.TrimSpace(c); c != "" {
+			paths = append(paths, "WEB-INF/classes/"+strings.ReplaceAll(c, ".", "/")+".class")
+		}
+	}
+	for _, s := range web.Servlets {
+		addClass(s.Class)
+		addPattern(strings.TrimSpace(s.JspFile))
+	}
+	for _, m := range web.ServletMappings {
+		for _, p := range m.Patterns {
+			addPattern(strings.TrimSpace(p))
+		}
+	}
+	for _, f := range web.Filters {
+		addClass(f.Class)
+	}
+	for _, m := range web.FilterMappings {
+		for _, p := range m.Patterns {
+			addPattern(strings.TrimSpace(p))
+		}
+	}
+	for _, l := range web.Listeners {
+		addClass(l.Class)
+	}
+	for _, p := range web.ContextParams {
+		// 例如 classpath:applicationContext.xml, /WEB-INF/spring-mvc.xml
+		for _, v := range strings.FieldsFunc(p.Value, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\t' }) {
+			switch ext := path.Ext(v); ext {
+			case ".xml", ".properties", ".yml", ".yaml":
+				if strings.HasPrefix(v, "classpath") {
+					v = "WEB-INF/classes/" + strings.TrimLeft(v[strings.Index(v, ":")+1:], "/")
+				}
+				addPattern(v)
+			}
+		}
+	}
+	for _, p := range append(web.WelcomeFiles, web.ErrorPages...) {
+		addPattern(strings.TrimSpace(p))
+	}
+	return paths
+}
@@ -0,0 +1,345 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+	"unicode/utf16"
+)
+
+// gitIndexFixture 生成包含names的.git/index, v4使用前缀压缩
+func gitIndexFixture(version uint32, names ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("DIRC")
+	binary.Write(&buf, binary.BigEndian, version)
+	binary.Write(&buf, binary.BigEndian, uint32(len(names)))
+	var prev string
+	for _, name := range names {
+		entry := make([]byte, 62)
+		flags := uint16(len(name))
+		if version == 3 {
+			flags |= 0x4000
+		}
+		binary.BigEndian.PutUint16(entry[60:], flags)
+		if version == 3 {
+			entry = append(entry, 0, 0)
+		}
+		if version == 4 {
+			common := 0
+			for common < len(prev) && common < len(name) && prev[common] == name[common] {
+				common++
+			}
+			entry = append(entry, byte(len(prev)-common))
+			entry = append(entry, name[common:]...)
+			entry = append(entry, 0)
+		} else {
+			entry = append(entry, name...)
+			entry = append(entry, make([]byte, 8-(len(entry)%8))...)
+		}
+		buf.Write(entry)
+		prev = name
+	}
+	// 模拟extension与checksum
+	buf.WriteString("TREE")
+	buf.Write(make([]byte, 24))
+	return buf.Bytes()
+}
+
+func dsStoreRecord(name, id, typ string, value []byte) []byte {
+	units := utf16.Encode([]rune(name))
+	buf := make([]byte, 4, 4+len(units)*2+8+len(value))
+	binary.BigEndian.PutUint32(buf, uint32(len(units)))
+	for _, u := range units {
+		buf = append(buf, byte(u>>8), byte(u))
+	}
+	buf = append(buf, id...)
+	buf = append(buf, typ...)
+	return append(buf, value...)
+}
+
+func dsStoreFixture() []byte {
+	data := append([]byte{0, 0, 0, 1}, "Bud1"...)
+	data = append(data, make([]byte, 28)...)
+	data = append(data, dsStoreRecord("admin", "lsvp", "bool", []byte{1})...)
+	data = append(data, dsStoreRecord("index.php", "Iloc", "blob", make([]byte, 20))...)
+	data = append(data, dsStoreRecord("index.php", "ph1S", "comp", make([]byte, 8))...)
+	data = append(data, dsStoreRecord("备份.zip", "modD", "dutc", make([]byte, 8))...)
+	return append(data, make([]byte, 16)...)
+}
+
+func sqliteVarintBytes(v uint64) []byte {
+	if v < 0x80 {
+		return []byte{byte(v)}
+	}
+	var out []byte
+	for v > 0 {
+		out = append([]byte{byte(v&0x7f) | 0x80}, out...)
+		v >>= 7
+	}
+	out[len(out)-1] &= 0x7f
+	return out
+}
+
+func sqliteRecordFixture(values ...interface{}) []byte {
+	var types, body []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			types = append(types, sqliteVarintBytes(uint64(13+2*len(v)))...)
+			body = append(body, v...)
+		case int:
+			types = append(types, 1)
+			body = append(body, byte(v))
+		default:
+			types = append(types, 0)
+		}
+	}
+	header := append(sqliteVarintBytes(uint64(len(types)+1)), types...)
+	return append(header, body...)
+}
+
+// svnWcDBFixture 生成只有一个table b-tree叶子页的sqlite文件, 每个record为NODES表中的一行
+func svnWcDBFixture(records ...[]byte) []byte {
+	const pageSize = 1024
+	data := make([]byte, pageSize)
+	copy(data, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(data[16:], pageSize)
+	data[100] = 0x0d
+	binary.BigEndian.PutUint16(data[103:], uint16(len(records)))
+	end := pageSize
+	for i, record := range records {
+		cell := append(sqliteVarintBytes(uint64(len(record))), sqliteVarintBytes(uint64(i+1))...)
+		cell = append(cell, record...)
+		end -= len(cell)
+		copy(data[end:], cell)
+		binary.BigEndian.PutUint16(data[108+i*2:], uint16(end))
+	}
+	return data
+}
+
+func svnNode(relpath, presence, kind, checksum string) []byte {
+	var sum interface{}
+	if checksum != "" {
+		sum = checksum
+	}
+	return sqliteRecordFixture(1, relpath, 0, "", 1, "trunk/"+relpath, 1, presence, nil, nil, kind, nil, nil, sum)
+}
+
+func hgDirstateFixture(names ...string) []byte {
+	data := make([]byte, 40)
+	for _, name := range names {
+		entry := make([]byte, 17)
+		entry[0] = 'n'
+		binary.BigEndian.PutUint32(entry[13:], uint32(len(name)))
+		data = append(append(data, entry...), name...)
+	}
+	return data
+}
+
+const sha1Fixture = "da39a3ee5e6b4b0d3255bfef95601890afd80709"
+
+var webXMLFixture = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<web-app>
+  <context-param>
+    <param-name>contextConfigLocation</param-name>
+    <param-value>classpath:applicationContext.xml, /WEB-INF/spring-mvc.xml</param-value>
+  </context-param>
+  <servlet>
+    <servlet-name>dispatcher</servlet-name>
+    <servlet-class>com.example.web.DispatcherServlet</servlet-class>
+  </servlet>
+  <servlet>
+    <servlet-name>status</servlet-name>
+    <jsp-file>/status.jsp</jsp-file>
+  </servlet>
+  <servlet-mapping>
+    <servlet-name>dispatcher</servlet-name>
+    <url-pattern>/api/*</url-pattern>
+    <url-pattern>*.do</url-pattern>
+  </servlet-mapping>
+  <filter>
+    <filter-class>com.example.AuthFilter</filter-class>
+  </filter>
+  <filter-mapping>
+    <url-pattern>/admin/*</url-pattern>
+  </filter-mapping>
+  <listener>
+    <listener-class>com.example.StartupListener</listener-class>
+  </listener>
+  <welcome-file-list>
+    <welcome-file>index.jsp</welcome-file>
+  </welcome-file-list>
+  <error-page>
+    <location>/error.jsp</location>
+  </error-page>
+</web-app>`)
+
+// leakFixtures 每个解析函数的合法输入, 同时用于截断与损坏测试
+var leakFixtures = []struct {
+	name  string
+	parse func([]byte) []string
+	data  []byte
+	want  []string
+}{
+	{"git index v2", ParseGitIndex, gitIndexFixture(2, ".gitignore", "src/main.go", "a"), []string{".gitignore", "src/main.go", "a"}},
+	{"git index v3", ParseGitIndex, gitIndexFixture(3, "README.md", "config/app.yml"), []string{"README.md", "config/app.yml"}},
+	{"git index v4", ParseGitIndex, gitIndexFixture(4, "src/a.go", "src/b.go", "src/sub/c.go", "z"), []string{"src/a.go", "src/b.go", "src/sub/c.go", "z"}},
+	{"git packed-refs", ParseGitRefs, []byte("# pack-refs with: peeled fully-peeled sorted \n" + sha1Fixture + " refs/heads/master\n^" + sha1Fixture + "\n" + sha1Fixture + " refs/tags/v1.0\n"),
+		[]string{".git/refs/heads/master", ".git/logs/refs/heads/master", ".git/refs/tags/v1.0", ".git/logs/refs/tags/v1.0"}},
+	{"git HEAD", ParseGitRefs, []byte("ref: refs/heads/dev\n"), []string{".git/refs/heads/dev", ".git/logs/refs/heads/dev"}},
+	{"svn entries", ParseSvnEntries, []byte("10\n\ndir\n5\nhttp://svn.example.com/trunk\n\x0c\nindex.php\nfile\n\n\x0c\nadmin\ndir\n\x0c\n"),
+		[]string{"index.php", ".svn/text-base/index.php.svn-base", "admin/", "admin/.svn/entries"}},
+	{"svn wc.db", ParseSvnWcDB, svnWcDBFixture(
+		svnNode("index.php", "normal", "file", "$sha1$"+sha1Fixture),
+		svnNode("admin", "normal", "dir", ""),
+		svnNode("deleted.php", "not-present", "file", ""),
+	), []string{"index.php", ".svn/pristine/da/" + sha1Fixture + ".svn-base", "admin/"}},
+	{"hg fncache", ParseHgFncache, []byte("data/index.php.i\ndata/conf/db.ini.d\nmeta/x\ndata/.i\n"), []string{"index.php", "conf/db.ini"}},
+	{"hg dirstate", ParseHgDirstate, hgDirstateFixture("index.php", "new.php\x00old.php"), []string{"index.php", "new.php"}},
+	{"ds_store", ParseDSStore, dsStoreFixture(), []string{"admin", "admin/.DS_Store", "index.php", "备份.zip"}},
+	{"web.xml", ParseWebXML, webXMLFixture, []string{
+		"WEB-INF/classes/applicationContext.xml", "WEB-INF/spring-mvc.xml",
+		"WEB-INF/classes/com/example/web/DispatcherServlet.class", "status.jsp", "api/",
+		"WEB-INF/classes/com/example/AuthFilter.class", "admin/",
+		"WEB-INF/classes/com/example/StartupListener.class", "index.jsp", "error.jsp",
+	}},
+}
+
+func TestLeakParsers(t *testing.T) {
+	for _, c := range leakFixtures {
+		got := c.parse(c.data)
+		want := append([]string(nil), c.want...)
+		sort.Strings(got)
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %q, want %q", c.name, got, want)
+		}
+	}
+}
+
+// TestLeakParsersTruncated 响应被截断时不能panic, 截断的结果只能是完整结果的子集
+func TestLeakParsersTruncated(t *testing.T) {
+	// 逐行解析的文本格式截断后最后一行可能不完整
+	lineBased := map[string]bool{"git packed-refs": true, "git HEAD": true, "hg fncache": true}
+	for _, c := range leakFixtures {
+		want := make(map[string]bool)
+		for _, p := range c.want {
+			want[p] = true
+		}
+		for i := 0; i < len(c.data); i++ {
+			for _, p := range c.parse(c.data[:i]) {
+				if !want[p] && !lineBased[c.name] {
+					t.Errorf("%s: truncated at %d returned unexpected %q", c.name, i, p)
+				}
+			}
+		}
+	}
+}
+
+// TestLeakParsersMalformed 随机损坏输入中的字节, 尤其是长度与计数字段, 不能越界
+func TestLeakParsersMalformed(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, c := range leakFixtures {
+		for i := 0; i < len(c.data); i++ {
+			for _, b := range []byte{0x00, 0x7f, 0x80, 0xff} {
+				data := append([]byte(nil), c.data...)
+				data[i] = b
+				c.parse(data)
+			}
+		}
+		for i := 0; i < 2000; i++ {
+			data := append([]byte(nil), c.data...)
+			for j := r.Intn(8) + 1; j > 0; j-- {
+				data[r.Intn(len(data))] = byte(r.Intn(256))
+			}
+			c.parse(data[:r.Intn(len(data)+1)])
+		}
+	}
+
+	garbage := make([]byte, 4096)
+	r.Read(garbage)
+	headers := [][]byte{[]byte("DIRC"), []byte("SQLite format 3\x00"), {0, 0, 0, 1, 'B', 'u', 'd', '1'}, nil}
+	for _, header := range headers {
+		data := append(append([]byte(nil), header...), garbage...)
+		for _, c := range leakFixtures {
+			c.parse(data)
+		}
+	}
+}
+
+func TestParseGitIndexHeader(t *testing.T) {
+	data := gitIndexFixture(2, "a")
+	for _, version := range []uint32{0, 1, 5, 0xffffffff} {
+		bad := append([]byte(nil), data...)
+		binary.BigEndian.PutUint32(bad[4:], version)
+		if got := ParseGitIndex(bad); got != nil {
+			t.Errorf("version %d: got %q", version, got)
+		}
+	}
+	// 声明的数量大于实际的entry时返回已经解析的部分
+	binary.BigEndian.PutUint32(data[8:], 0xffffffff)
+	if got := ParseGitIndex(data); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("oversized count: got %q", got)
+	}
+	if got := ParseGitIndex([]byte("DIRX\x00\x00\x00\x02\x00\x00\x00\x01")); got != nil {
+		t.Errorf("bad signature: got %q", got)
+	}
+}
+
+func TestLeakFiles(t *testing.T) {
+	cases := []struct {
+		path  string
+		root  string
+		files []string
+	}{
+		{"/app/.git/config", "/app/", LeakTriggers[".git/"]},
+		{".svn/entries", "/", LeakTriggers[".svn/"]},
+		{"/a/b/WEB-INF/lib/x.jar", "/a/b/", LeakTriggers["WEB-INF/"]},
+		{"/static/app.js", "", nil},
+	}
+	for _, c := range cases {
+		root, files := LeakFiles(c.path)
+		if root != c.root || !reflect.DeepEqual(files, c.files) {
+			t.Errorf("LeakFiles(%s) = %s, %v", c.path, root, files)
+		}
+	}
+}
+
+func TestParseLeak(t *testing.T) {
+	leak := ParseLeak("/app/.git/index", gitIndexFixture(2, "a.php", "a.php", "b.php"))
+	if leak == nil {
+		t.Fatal("ParseLeak returned nil")
+	}
+	if leak.Name != "git" || leak.File != ".git/index" || leak.Root != "/app/" || !reflect.DeepEqual(leak.Paths, []string{"a.php", "b.php"}) {
+		t.Errorf("ParseLeak = %+v", leak)
+	}
+	if leak := ParseLeak("/app/.git/index", []byte("not an index")); leak != nil {
+		t.Errorf("invalid index returned %+v", leak)
+	}
+	if leak := ParseLeak("/app/index", gitIndexFixture(2, "a.php")); leak != nil {
+		t.Errorf("unknown file returned %+v", leak)
+	}
+}
+
+// TestSqliteRecordsOverflow 超过int范围的varint不能转换为负数的长度
+func TestSqliteRecordsOverflow(t *testing.T) {
+	huge := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	record := svnNode("index.php", "normal", "file", "")
+	cases := map[string][]byte{
+		"payload":     append(append(append([]byte(nil), huge...), 1), record...),
+		"header size": append(append([]byte{byte(len(record) + 10), 1}, huge...), record...),
+		"serial type": append([]byte{byte(len(record) + 10), 1}, append([]byte{11, 1}, append(huge, record[2:]...)...)...),
+	}
+	for name, cell := range cases {
+		data := svnWcDBFixture()
+		binary.BigEndian.PutUint16(data[103:], 1)
+		binary.BigEndian.PutUint16(data[108:], uint16(len(data)-len(cell)))
+		copy(data[len(data)-len(cell):], cell)
+		if got := ParseSvnWcDB(data); len(got) != 0 {
+			t.Errorf("%s: got %q", name, got)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package pkg
+
+import (
+	"github.com/chainreactors/parsers"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	MirrorSkip   = "skip"
+	MirrorSample = "sample"
+	MirrorReuse  = "reuse"
+)
+
+func NewMirrors() *Mirrors {
+	return &Mirrors{}
+}
+
+// Mirrors 记录所有已经初始化的目标的index与random baseline, 用来发现不同ip/端口上的同一个应用
+type Mirrors struct {
+	locker  sync.Mutex
+	mirrors []*Mirror
+}
+
+// Match 与已有的目标对比, 如果找到相似的目标, 将当前目标加入该组并返回true; 否则以当前目标为首个成员新建一组
+func (ms *Mirrors) Match(baseURL, base string, index, random *Baseline) (*Mirror, bool) {
+	ms.locker.Lock()
+	defer ms.locker.Unlock()
+	for _, m := range ms.mirrors {
+		if m.Similar(index, random) {
+			m.Members = append(m.Members, baseURL)
+			return m, true
+		}
+	}
+	m := &Mirror{
+		Primary: baseURL,
+		base:    base,
+		index:   index,
+		random:  random,
+	}
+	ms.mirrors = append(ms.mirrors, m)
+	return m, false
+}
+
+type Mirror struct {
+	Primary string   // 第一个完成初始化的目标
+	Members []string // 被判断为镜像的目标
+	base    string
+	index   *Baseline
+	random  *Baseline
+	locker  sync.Mutex
+	results []*Baseline
+	done    bool
+	pending []string
+}
+
+// Similar index与random同时相似才认为是同一个应用, 只有index相似的可能只是同一套cdn或者默认页
+func (m *Mirror) Similar(index, random *Baseline) bool {
+	return similarBaseline(m.index, index) && similarBaseline(m.random, random)
+}
+
+func similarBaseline(a, b *Baseline) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	if a.Status != b.Status || a.Title != b.Title || frameworkNames(a) != frameworkNames(b) {
+		return false
+	}
+	if redirectPath(a.RedirectURL) != redirectPath(b.RedirectURL) {
+		return false
+	}
+	if a.Hashes == nil || b.Hashes == nil {
+		return a.BodyLength == b.BodyLength
+	}
+	if a.BodyMd5 == b.BodyMd5 {
+		return true
+	}
+	return parsers.SimhashCompare(a.BodySimhash, b.BodySimhash) < Distance
+}
+
+func frameworkNames(bl *Baseline) string {
+	var names []string
+	for name := range bl.Frameworks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// redirectPath 不同镜像的重定向地址中通常包含各自的host, 只对比path部分
+func redirectPath(u string) string {
+	if u == "" {
+		return ""
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	return parsed.Path
+}
+
+// AddResult 收集首个成员的有效结果, 用于reuse模式
+func (m *Mirror) AddResult(bl *Baseline) {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	m.results = append(m.results, bl)
+}
+
+// Reuse 如果首个成员已经完成, 返回重写到target上的结果; 否则挂起, 等待Finish时返回
+func (m *Mirror) Reuse(target string) ([]*Baseline, bool) {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	if !m.done {
+		m.pending = append(m.pending, target)
+		return nil, false
+	}
+	return m.rebase(target), true
+}
+
+// Finish 首个成员完成后调用, 返回所有挂起的镜像目标对应的结果
+func (m *Mirror) Finish() map[string][]*Baseline {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	m.done = true
+	reused := make(map[string][]*Baseline)
+	for _, target := range m.pending {
+		reused[target] = m.rebase(target)
+	}
+	m.pending = nil
+	return reused
+}
+
+func (m *Mirror) rebase(target string) []*Baseline {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil
+	}
+	targetBase := u.Scheme + "://" + u.Host
+	var bls []*Baseline
+	for _, bl := range m.results {
+		result := *bl.SprayResult
+		nbl := *bl
+		nbl.SprayResult = &result
+		nbl.UrlString = targetBase + strings.TrimPrefix(bl.UrlString, m.base)
+		nbl.Url, _ = url.Parse(nbl.UrlString)
+		nbl.Recu = false
+		nbl.Extracteds = append(append([]*parsers.Extracted{}, bl.Extracteds...), m.Extracted())
+		bls = append(bls, &nbl)
+	}
+	return bls
+}
+
+func (m *Mirror) Extracted() *parsers.Extracted {
+	return &parsers.Extracted{
+		Name:          "mirror",
+		ExtractResult: []string{m.Primary},
+	}
+}
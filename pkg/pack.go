@@ -0,0 +1,40 @@
+package pkg
+
+import (
+	"github.com/chainreactors/parsers"
+	"strings"
+)
+
+// Pack 框架专属的路径包, 识别到对应的指纹后自动加入任务, 例如spring boot的actuator, tomcat的manager
+type Pack struct {
+	Name       string   `json:"name"`
+	Frameworks []string `json:"frameworks"` // 指纹名中的关键字, 忽略大小写与"-", "_", " "
+	Paths      []string `json:"paths"`      // 相对于指纹所在目录的路径
+}
+
+// Packs 从templates中加载的路径包
+var Packs []*Pack
+
+// MatchPacks 根据指纹选择路径包, 返回路径包与触发它的指纹名
+func MatchPacks(frameworks parsers.Frameworks) map[*Pack]string {
+	matched := make(map[*Pack]string)
+	for name := range frameworks {
+		normalized := normalizeFramework(name)
+		for _, pack := range Packs {
+			if _, ok := matched[pack]; ok {
+				continue
+			}
+			for _, f := range pack.Frameworks {
+				if strings.Contains(normalized, normalizeFramework(f)) {
+					matched[pack] = name
+					break
+				}
+			}
+		}
+	}
+	return matched
+}
+
+func normalizeFramework(name string) string {
+	return strings.NewReplacer("-", "", "_", "", " ", "").Replace(strings.ToLower(name))
+}
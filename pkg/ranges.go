@@ -0,0 +1,118 @@
+package pkg
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+func NewRanges() *Ranges {
+	return &Ranges{}
+}
+
+// Ranges 通过游程编码记录已经完成的word序号, 连续完成的序号只占用一个区间.
+// 并发请求下word完成的顺序是乱序的, 但绝大部分会很快合并成少量的区间, 保存到stat中的体积很小
+type Ranges struct {
+	locker sync.Mutex
+	spans  [][2]int // 有序且互不相邻的闭区间
+	count  int
+}
+
+func (r *Ranges) search(n int) int {
+	// 第一个end >= n-1的区间, 即可能包含n或者与n相邻的区间
+	return sort.Search(len(r.spans), func(i int) bool {
+		return r.spans[i][1] >= n-1
+	})
+}
+
+func (r *Ranges) Add(n int) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	i := r.search(n)
+	if i < len(r.spans) {
+		span := &r.spans[i]
+		if span[0] <= n && n <= span[1] {
+			// 已经记录过
+			return
+		}
+		if span[1] == n-1 {
+			span[1] = n
+			if i+1 < len(r.spans) && r.spans[i+1][0] == n+1 {
+				// 填补了两个区间之间的空隙, 合并
+				span[1] = r.spans[i+1][1]
+				r.spans = append(r.spans[:i+1], r.spans[i+2:]...)
+			}
+			r.count++
+			return
+		}
+		if span[0] == n+1 {
+			span[0] = n
+			r.count++
+			return
+		}
+	}
+
+	r.spans = append(r.spans, [2]int{})
+	copy(r.spans[i+1:], r.spans[i:])
+	r.spans[i] = [2]int{n, n}
+	r.count++
+}
+
+func (r *Ranges) Contains(n int) bool {
+	if r == nil {
+		return false
+	}
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	i := r.search(n)
+	return i < len(r.spans) && r.spans[i][0] <= n && n <= r.spans[i][1]
+}
+
+// Count 已完成的word总数
+func (r *Ranges) Count() int {
+	if r == nil {
+		return 0
+	}
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	return r.count
+}
+
+func (r *Ranges) MarshalJSON() ([]byte, error) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	if r.spans == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(r.spans)
+}
+
+func (r *Ranges) UnmarshalJSON(data []byte) error {
+	var spans [][2]int
+	if err := json.Unmarshal(data, &spans); err != nil {
+		return err
+	}
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i][0] < spans[j][0]
+	})
+	r.spans = nil
+	r.count = 0
+	for _, span := range spans {
+		if span[1] < span[0] {
+			continue
+		}
+		if last := len(r.spans) - 1; last >= 0 && span[0] <= r.spans[last][1]+1 {
+			// 手动修改过的stat中可能存在重叠或相邻的区间, 合并
+			if span[1] > r.spans[last][1] {
+				r.count += span[1] - r.spans[last][1]
+				r.spans[last][1] = span[1]
+			}
+			continue
+		}
+		r.spans = append(r.spans, span)
+		r.count += span[1] - span[0] + 1
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package pkg
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRangesAdd(t *testing.T) {
+	cases := []struct {
+		name  string
+		adds  []int
+		spans [][2]int
+		count int
+	}{
+		{"empty", nil, nil, 0},
+		{"single", []int{5}, [][2]int{{5, 5}}, 1},
+		{"adjacent ascending", []int{1, 2, 3, 4}, [][2]int{{1, 4}}, 4},
+		{"adjacent descending", []int{4, 3, 2, 1}, [][2]int{{1, 4}}, 4},
+		{"duplicate", []int{1, 1, 2, 2, 1}, [][2]int{{1, 2}}, 2},
+		{"gap", []int{1, 3}, [][2]int{{1, 1}, {3, 3}}, 2},
+		{"fill gap coalesces", []int{1, 3, 2}, [][2]int{{1, 3}}, 3},
+		{"fill gap between spans", []int{1, 2, 5, 6, 3, 4}, [][2]int{{1, 6}}, 6},
+		{"overlapping adds", []int{1, 2, 3, 2, 3, 4, 3}, [][2]int{{1, 4}}, 4},
+		{"out of order", []int{10, 0, 7, 3, 8, 1, 9, 2}, [][2]int{{0, 3}, {7, 10}}, 8},
+		{"insert before first", []int{5, 6, 1}, [][2]int{{1, 1}, {5, 6}}, 3},
+		{"extend left", []int{5, 6, 4}, [][2]int{{4, 6}}, 3},
+		{"zero", []int{0, 1}, [][2]int{{0, 1}}, 2},
+	}
+	for _, c := range cases {
+		r := NewRanges()
+		for _, n := range c.adds {
+			r.Add(n)
+		}
+		if !reflect.DeepEqual(r.spans, c.spans) {
+			t.Errorf("%s: spans = %v, want %v", c.name, r.spans, c.spans)
+		}
+		if r.Count() != c.count {
+			t.Errorf("%s: Count() = %d, want %d", c.name, r.Count(), c.count)
+		}
+	}
+}
+
+func TestRangesContains(t *testing.T) {
+	r := NewRanges()
+	for _, n := range []int{0, 1, 2, 5, 7, 8} {
+		r.Add(n)
+	}
+	cases := map[int]bool{-1: false, 0: true, 2: true, 3: false, 4: false, 5: true, 6: false, 7: true, 8: true, 9: false}
+	for n, want := range cases {
+		if got := r.Contains(n); got != want {
+			t.Errorf("Contains(%d) = %v, want %v", n, got, want)
+		}
+	}
+
+	var empty *Ranges
+	if empty.Contains(0) || empty.Count() != 0 {
+		t.Error("nil Ranges should be empty")
+	}
+}
+
+func TestRangesJSON(t *testing.T) {
+	r := NewRanges()
+	if content, _ := json.Marshal(r); string(content) != "[]" {
+		t.Errorf("empty ranges marshal to %s, want []", content)
+	}
+	for _, n := range []int{9, 1, 2, 3, 7} {
+		r.Add(n)
+	}
+	content, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "[[1,3],[7,7],[9,9]]" {
+		t.Errorf("marshal = %s", content)
+	}
+
+	// 乱序与非法的区间
+	restored := NewRanges()
+	if err := json.Unmarshal([]byte("[[9,9],[1,3],[5,4],[7,7]]"), restored); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored.spans, r.spans) || restored.Count() != 5 {
+		t.Errorf("unmarshal = %v (%d), want %v (5)", restored.spans, restored.Count(), r.spans)
+	}
+	// 重叠与相邻的区间合并
+	if err := json.Unmarshal([]byte("[[3,8],[1,5],[9,9],[2,3],[12,14]]"), restored); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored.spans, [][2]int{{1, 9}, {12, 14}}) || restored.Count() != 12 {
+		t.Errorf("unmarshal overlapping = %v (%d)", restored.spans, restored.Count())
+	}
+	if err := json.Unmarshal([]byte(`{"a":1}`), restored); err == nil {
+		t.Error("unmarshal object should fail")
+	}
+}
+
+func TestStatistorCompletedRoundTrip(t *testing.T) {
+	stat := NewStatistor("http://example.com")
+	stat.Total = 100
+	for _, n := range []int{0, 1, 2, 3, 10, 11, 50} {
+		stat.Completed.Add(n)
+	}
+
+	dir, err := ioutil.TempDir("", "spray")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "stat.json")
+	if err := ioutil.WriteFile(filename, []byte(stat.Json()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := ReadStatistors(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("read %d stats, want 1", len(stats))
+	}
+
+	resumed := NewStatistorFromStat(stats[0])
+	if resumed.CompletedNumber() != 7 {
+		t.Errorf("CompletedNumber() = %d, want 7", resumed.CompletedNumber())
+	}
+	for n := 0; n < 60; n++ {
+		if resumed.Completed.Contains(n) != stat.Completed.Contains(n) {
+			t.Errorf("Contains(%d) differs after round trip", n)
+		}
+	}
+	// 续传后新完成的word继续合并
+	resumed.Completed.Add(4)
+	if !reflect.DeepEqual(resumed.Completed.spans, [][2]int{{0, 4}, {10, 11}, {50, 50}}) {
+		t.Errorf("spans after resume = %v", resumed.Completed.spans)
+	}
+
+	// 旧版本的stat没有completed, 从end处继续
+	old := &Statistor{BaseUrl: "http://example.com", End: 30}
+	if resumed := NewStatistorFromStat(old); resumed.Offset != 30 || resumed.CompletedNumber() != 0 {
+		t.Errorf("legacy resume offset = %d, completed = %d", resumed.Offset, resumed.CompletedNumber())
+	}
+}
@@ -0,0 +1,113 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// WellKnownPaths 常见的/.well-known/ URI
+var WellKnownPaths = []string{
+	"security.txt", "openid-configuration", "oauth-authorization-server", "jwks.json", "change-password",
+	"assetlinks.json", "apple-app-site-association", "host-meta", "host-meta.json", "webfinger", "nodeinfo",
+	"mta-sts.txt", "dnt-policy.txt", "caldav", "carddav", "matrix/client", "matrix/server",
+}
+
+// SitemapPaths 没有在robots.txt中声明时, 尝试的默认sitemap
+var SitemapPaths = []string{"/sitemap.xml", "/sitemap_index.xml", "/sitemap.xml.gz"}
+
+// MaxSitemapSize 协议规定的sitemap解压后的大小上限
+const MaxSitemapSize = 50 * 1024 * 1024
+
+// ParseRobots 解析robots.txt, 返回Allow与Disallow中的路径以及声明的sitemap. 路径中的通配符之后的部分会被丢弃
+func ParseRobots(content []byte) ([]string, []string) {
+	var paths, sitemaps []string
+	// 不使用bufio.Scanner, 超长的行会导致之后的规则全部被丢弃
+	for _, line := range strings.Split(string(content), "\n") {
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+		i := strings.Index(line, ":")
+		if i == -1 {
+			continue
+		}
+		key, value := strings.ToLower(strings.TrimSpace(line[:i])), strings.TrimSpace(line[i+1:])
+		switch key {
+		case "allow", "disallow":
+			if i := strings.IndexAny(value, "*$"); i != -1 {
+				value = value[:i]
+			}
+			if strings.HasPrefix(value, "/") && value != "/" {
+				paths = append(paths, value)
+			}
+		case "sitemap":
+			if value != "" {
+				sitemaps = append(sitemaps, value)
+			}
+		}
+	}
+	return RemoveDuplication(paths), RemoveDuplication(sitemaps)
+}
+
+// ParseSitemap 解析sitemap与sitemap index, 支持gzip压缩与纯文本格式. 返回页面url与下一级的sitemap.
+// 响应被截断时返回已经解析的部分
+func ParseSitemap(content []byte) ([]string, []string) {
+	if len(content) > 2 && content[0] == 0x1f && content[1] == 0x8b {
+		if reader, err := gzip.NewReader(bytes.NewReader(content)); err == nil {
+			// 截断的gzip同样保留已经解压的部分
+			content, _ = ioutil.ReadAll(io.LimitReader(reader, MaxSitemapSize))
+		}
+	}
+
+	var urls, sitemaps []string
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.Strict = false
+	var inSitemap, inLoc bool
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			// io.EOF或者响应被截断
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "sitemap":
+				inSitemap = true
+			case "loc":
+				inLoc = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "sitemap":
+				inSitemap = false
+			case "loc":
+				inLoc = false
+			}
+		case xml.CharData:
+			if !inLoc {
+				continue
+			}
+			if loc := strings.TrimSpace(string(t)); loc == "" {
+				continue
+			} else if inSitemap {
+				sitemaps = append(sitemaps, loc)
+			} else {
+				urls = append(urls, loc)
+			}
+		}
+	}
+
+	if len(urls) == 0 && len(sitemaps) == 0 {
+		// 纯文本格式, 每行一个url
+		for _, line := range strings.Split(string(content), "\n") {
+			if line = strings.TrimSpace(line); strings.HasPrefix(line, "http://") || strings.HasPrefix(line, "https://") {
+				urls = append(urls, line)
+			}
+		}
+	}
+	return RemoveDuplication(urls), RemoveDuplication(sitemaps)
+}
@@ -0,0 +1,150 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var robotsFixture = []byte("# robots\r\n" +
+	"User-agent: *\r\n" +
+	"Disallow: /admin/\r\n" +
+	"Disallow: /\r\n" +
+	"Allow: /public/*.html$\r\n" +
+	"disallow:/private # comment\r\n" +
+	"Disallow: *.php\r\n" +
+	"Disallow:\r\n" +
+	"Crawl-delay: 10\r\n" +
+	"Noindex /broken\r\n" +
+	"Disallow: /admin/\r\n" +
+	"Sitemap: https://example.com/sitemap.xml\r\n" +
+	"SITEMAP:https://example.com/news.xml")
+
+func TestParseRobots(t *testing.T) {
+	paths, sitemaps := ParseRobots(robotsFixture)
+	if want := []string{"/admin/", "/public/", "/private"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %q, want %q", paths, want)
+	}
+	if want := []string{"https://example.com/sitemap.xml", "https://example.com/news.xml"}; !reflect.DeepEqual(sitemaps, want) {
+		t.Errorf("sitemaps = %q, want %q", sitemaps, want)
+	}
+
+	for i := 0; i < len(robotsFixture); i++ {
+		ParseRobots(robotsFixture[:i])
+	}
+	if paths, sitemaps := ParseRobots([]byte("\x00\xff\xfe:::\n\n:")); len(paths) != 0 || len(sitemaps) != 0 {
+		t.Errorf("garbage = %q, %q", paths, sitemaps)
+	}
+}
+
+// TestParseRobotsLongLine 超长的行不能导致之后的规则被丢弃
+func TestParseRobotsLongLine(t *testing.T) {
+	content := "Disallow: /a\n# " + strings.Repeat("x", 128*1024) + "\nDisallow: /b\n"
+	if paths, _ := ParseRobots([]byte(content)); !reflect.DeepEqual(paths, []string{"/a", "/b"}) {
+		t.Errorf("paths = %q", paths)
+	}
+}
+
+var sitemapFixture = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/</loc><lastmod>2023-01-01</lastmod></url>
+  <url><loc>
+    https://example.com/about
+  </loc></url>
+  <url><loc>https://example.com/a?x=1&amp;y=2</loc></url>
+  <url><loc>https://example.com/</loc></url>
+</urlset>`)
+
+var sitemapIndexFixture = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://example.com/sitemap-1.xml</loc></sitemap>
+  <sitemap><loc>https://example.com/sitemap-2.xml.gz</loc></sitemap>
+</sitemapindex>`)
+
+func gzipFixture(t *testing.T, content []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseSitemap(t *testing.T) {
+	pages := []string{"https://example.com/", "https://example.com/about", "https://example.com/a?x=1&y=2"}
+	index := []string{"https://example.com/sitemap-1.xml", "https://example.com/sitemap-2.xml.gz"}
+	cases := []struct {
+		name     string
+		content  []byte
+		urls     []string
+		sitemaps []string
+	}{
+		{"urlset", sitemapFixture, pages, nil},
+		{"index", sitemapIndexFixture, nil, index},
+		{"gzip", gzipFixture(t, sitemapFixture), pages, nil},
+		{"text", []byte("https://example.com/a\r\n\r\nftp://example.com/b\nhttp://example.com/c \n"), []string{"https://example.com/a", "http://example.com/c"}, nil},
+		{"unclosed tags", []byte("<urlset><url><loc>https://example.com/x<url><loc>https://example.com/y"), []string{"https://example.com/x", "https://example.com/y"}, nil},
+		{"html", []byte("<html><body>not found</body></html>"), nil, nil},
+		{"empty", nil, nil, nil},
+	}
+	for _, c := range cases {
+		urls, sitemaps := ParseSitemap(c.content)
+		if len(urls) != len(c.urls) || len(urls) != 0 && !reflect.DeepEqual(urls, c.urls) {
+			t.Errorf("%s: urls = %q, want %q", c.name, urls, c.urls)
+		}
+		if len(sitemaps) != len(c.sitemaps) || len(sitemaps) != 0 && !reflect.DeepEqual(sitemaps, c.sitemaps) {
+			t.Errorf("%s: sitemaps = %q, want %q", c.name, sitemaps, c.sitemaps)
+		}
+	}
+}
+
+// TestParseSitemapTruncated 响应被截断时返回已经解析的部分, 且不会返回不完整的url
+func TestParseSitemapTruncated(t *testing.T) {
+	// 截断在</loc>之前时, 最后一个loc的内容可能不完整, 只检查之前已经闭合的url
+	i := bytes.Index(sitemapFixture, []byte("<url><loc>https://example.com/a?x"))
+	urls, _ := ParseSitemap(sitemapFixture[:i+20])
+	if want := []string{"https://example.com/", "https://example.com/about"}; len(urls) < 2 || !reflect.DeepEqual(urls[:2], want) {
+		t.Errorf("truncated urls = %q", urls)
+	}
+
+	compressed := gzipFixture(t, sitemapFixture)
+	for _, content := range [][]byte{sitemapFixture, sitemapIndexFixture, compressed} {
+		for i := 0; i < len(content); i++ {
+			ParseSitemap(content[:i])
+		}
+	}
+	// 截断的gzip保留已经解压的部分
+	if urls, _ := ParseSitemap(compressed[:len(compressed)-8]); len(urls) != 3 {
+		t.Errorf("truncated gzip urls = %q", urls)
+	}
+	// gzip头损坏时按照原始内容处理
+	if urls, sitemaps := ParseSitemap([]byte{0x1f, 0x8b, 0xff, 0xff}); len(urls) != 0 || len(sitemaps) != 0 {
+		t.Errorf("broken gzip = %q, %q", urls, sitemaps)
+	}
+}
+
+// TestParseSitemapGzipLimit 解压后的内容超过MaxSitemapSize时截断
+func TestParseSitemapGzipLimit(t *testing.T) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte("https://example.com/first\n"))
+	padding := make([]byte, 1024*1024)
+	for i := range padding {
+		padding[i] = ' '
+	}
+	for i := 0; i < MaxSitemapSize/len(padding); i++ {
+		w.Write(padding)
+	}
+	w.Write([]byte("\nhttps://example.com/last\n"))
+	w.Close()
+
+	urls, _ := ParseSitemap(buf.Bytes())
+	if !reflect.DeepEqual(urls, []string{"https://example.com/first"}) {
+		t.Errorf("urls = %q", urls)
+	}
+}
@@ -19,51 +19,149 @@ func NewStatistor(url string) *Statistor {
 	stat.StartTime = time.Now().Unix()
 	stat.Counts = make(map[int]int)
 	stat.Sources = make(map[int]int)
+	stat.Errors = make(map[string]int)
+	stat.BakGenerated = make(map[string]int)
+	stat.BakFound = make(map[string]int)
+	stat.APIClasses = make(map[string]int)
+	stat.Completed = NewRanges()
 	stat.BaseUrl = url
 	return &stat
 }
 
 func NewStatistorFromStat(origin *Statistor) *Statistor {
-	return &Statistor{
-		BaseUrl:      origin.BaseUrl,
-		Word:         origin.Word,
-		Dictionaries: origin.Dictionaries,
-		Offset:       origin.End,
-		RuleFiles:    origin.RuleFiles,
-		RuleFilter:   origin.RuleFilter,
-		Counts:       make(map[int]int),
-		Sources:      map[int]int{},
-		StartTime:    time.Now().Unix(),
+	stat := &Statistor{
+		BaseUrl:            origin.BaseUrl,
+		Word:               origin.Word,
+		Dictionaries:       origin.Dictionaries,
+		RuleFiles:          origin.RuleFiles,
+		RuleFilter:         origin.RuleFilter,
+		Counts:             make(map[int]int),
+		Sources:            map[int]int{},
+		Errors:             make(map[string]int),
+		BakGenerated:       make(map[string]int),
+		BakFound:           make(map[string]int),
+		APIClasses:         make(map[string]int),
+		StartTime:          time.Now().Unix(),
+		Depth:              origin.Depth,
+		Recursions:         origin.Recursions,
+		Generator:          origin.Generator,
+		Policy:             origin.Policy,
+		AutoExtensions:     origin.AutoExtensions,
+		ExtensionEvidences: origin.ExtensionEvidences,
 	}
+	if origin.Completed != nil {
+		// 通过已完成的word序号精确还原进度, 只发送缺失的word
+		stat.Offset = origin.Offset
+		stat.Completed = origin.Completed
+	} else {
+		// 兼容旧版本的stat, 从end处继续
+		stat.Offset = origin.End
+		stat.Completed = NewRanges()
+	}
+	return stat
+}
+
+// Addition 未处理的插件任务, 用于断点续传时恢复crawl, bak, common, active等插件的待处理队列
+type Addition struct {
+	Path   string       `json:"path"`
+	Source int          `json:"source"`
+	Depth  int          `json:"depth,omitempty"`
+	Retry  int          `json:"retry,omitempty"`
+	From   string       `json:"from,omitempty"`
+	API    *APIEndpoint `json:"api,omitempty"`
+}
+
+// Generator 字典生成与过滤相关的配置, 断点续传时用来完整还原任务
+type Generator struct {
+	Extensions        []string          `json:"extensions,omitempty"`
+	ExcludeExtensions []string          `json:"exclude_extensions,omitempty"`
+	RemoveExtensions  []string          `json:"remove_extensions,omitempty"`
+	Prefixes          []string          `json:"prefixes,omitempty"`
+	Suffixes          []string          `json:"suffixes,omitempty"`
+	Replaces          map[string]string `json:"replaces,omitempty"`
+	Uppercase         bool              `json:"uppercase,omitempty"`
+	Lowercase         bool              `json:"lowercase,omitempty"`
+	AppendRules       []string          `json:"append_rules,omitempty"`
+	MatchExpr         string            `json:"match,omitempty"`
+	FilterExpr        string            `json:"filter,omitempty"`
+	RecuExpr          string            `json:"recursive,omitempty"`
+	MaxDepth          int               `json:"max_depth,omitempty"`
+	Stream            bool              `json:"stream,omitempty"`
+	LineCounts        map[string]int    `json:"line_counts,omitempty"` // 流式字典的行数, 断点续传时不再重新统计
 }
 
 type Statistor struct {
-	BaseUrl        string      `json:"url"`
-	Error          string      `json:"error"`
-	Counts         map[int]int `json:"counts"`
-	Sources        map[int]int `json:"sources"`
-	FailedNumber   int32       `json:"failed"`
-	ReqTotal       int32       `json:"req_total"`
-	CheckNumber    int         `json:"check"`
-	FoundNumber    int         `json:"found"`
-	FilteredNumber int         `json:"filtered"`
-	FuzzyNumber    int         `json:"fuzzy"`
-	WafedNumber    int         `json:"wafed"`
-	End            int         `json:"end"`
-	Offset         int         `json:"offset"`
-	Total          int         `json:"total"`
-	StartTime      int64       `json:"start_time"`
-	EndTime        int64       `json:"end_time"`
-	WordCount      int         `json:"word_count"`
-	Word           string      `json:"word"`
-	Dictionaries   []string    `json:"dictionaries"`
-	RuleFiles      []string    `json:"rule_files"`
-	RuleFilter     string      `json:"rule_filter"`
+	BaseUrl            string         `json:"url"`
+	Error              string         `json:"error"`
+	Counts             map[int]int    `json:"counts"`
+	Sources            map[int]int    `json:"sources"`
+	FailedNumber       int32          `json:"failed"`
+	ReqTotal           int32          `json:"req_total"`
+	CheckNumber        int            `json:"check"`
+	FoundNumber        int            `json:"found"`
+	FilteredNumber     int            `json:"filtered"`
+	FuzzyNumber        int            `json:"fuzzy"`
+	WafedNumber        int            `json:"wafed"`
+	End                int            `json:"end"`
+	Offset             int            `json:"offset"`
+	Total              int            `json:"total"`
+	StartTime          int64          `json:"start_time"`
+	EndTime            int64          `json:"end_time"`
+	WordCount          int            `json:"word_count"`
+	Word               string         `json:"word"`
+	Dictionaries       []string       `json:"dictionaries"`
+	RuleFiles          []string       `json:"rule_files"`
+	RuleFilter         string         `json:"rule_filter"`
+	Completed          *Ranges        `json:"completed,omitempty"`
+	Depth              int            `json:"depth"`
+	Additions          []*Addition    `json:"additions,omitempty"`
+	Recursions         []string       `json:"recursions,omitempty"`
+	Generator          *Generator     `json:"generator,omitempty"`
+	MirrorOf           string         `json:"mirror_of,omitempty"`
+	Bans               []*Ban         `json:"bans,omitempty"`
+	Waf                string         `json:"waf,omitempty"`
+	Rebaselines        int            `json:"rebaselines,omitempty"`
+	Errors             map[string]int `json:"errors,omitempty"`              // 按类型统计的请求错误
+	Stopped            string         `json:"stopped,omitempty"`             // 因deadline或预算提前停止的原因
+	Vhosts             []*Vhost       `json:"vhosts,omitempty"`              // host模式下该ip上发现的虚拟主机
+	Policy             string         `json:"policy,omitempty"`              // 递归任务使用的递归策略
+	AutoExtensions     []string       `json:"auto_extensions,omitempty"`     // --auto-ext为该目标选择的后缀
+	ExtensionEvidences []string       `json:"extension_evidences,omitempty"` // 选择后缀的依据
+	BakGenerated       map[string]int `json:"bak_generated,omitempty"`       // 目录打包与上下文备份文件的数量, 按类型统计
+	BakFound           map[string]int `json:"bak_found,omitempty"`           // 其中命中的数量
+	APIClasses         map[string]int `json:"api_classes,omitempty"`         // 接口文档中的接口按响应分类(200/401/403/405/other/untested)统计
+	APIUntested        []string       `json:"api_untested,omitempty"`        // 未指定--api-unsafe时跳过的接口
+}
+
+// Ban 一次封禁的冷却过程
+type Ban struct {
+	Start  int64  `json:"start"`
+	End    int64  `json:"end"`
+	Probes int    `json:"probes"`
+	Reason string `json:"reason"`
+	GaveUp bool   `json:"gave_up,omitempty"`
+}
+
+// BanTime 所有冷却的总时长(秒)
+func (stat *Statistor) BanTime() int64 {
+	var t int64
+	for _, ban := range stat.Bans {
+		t += ban.End - ban.Start
+	}
+	return t
+}
+
+// CompletedNumber 实际完成的word数量, 并发请求下End只代表已经取出的word数量
+func (stat *Statistor) CompletedNumber() int {
+	if stat.Completed == nil {
+		return stat.End
+	}
+	return stat.Completed.Count()
 }
 
 func (stat *Statistor) ColorString() string {
 	var s strings.Builder
-	s.WriteString(fmt.Sprintf("[stat] %s took %d s, request total: %s, finish: %s/%s, found: %s, check: %s, failed: %s", logs.GreenLine(stat.BaseUrl), stat.EndTime-stat.StartTime, logs.YellowBold(strconv.Itoa(int(stat.ReqTotal))), logs.YellowBold(strconv.Itoa(stat.End)), logs.YellowBold(strconv.Itoa(stat.Total)), logs.YellowBold(strconv.Itoa(stat.FoundNumber)), logs.YellowBold(strconv.Itoa(stat.CheckNumber)), logs.YellowBold(strconv.Itoa(int(stat.FailedNumber)))))
+	s.WriteString(fmt.Sprintf("[stat] %s took %d s, request total: %s, finish: %s/%s, found: %s, check: %s, failed: %s", logs.GreenLine(stat.BaseUrl), stat.EndTime-stat.StartTime, logs.YellowBold(strconv.Itoa(int(stat.ReqTotal))), logs.YellowBold(strconv.Itoa(stat.CompletedNumber())), logs.YellowBold(strconv.Itoa(stat.Total)), logs.YellowBold(strconv.Itoa(stat.FoundNumber)), logs.YellowBold(strconv.Itoa(stat.CheckNumber)), logs.YellowBold(strconv.Itoa(int(stat.FailedNumber)))))
 
 	if stat.FuzzyNumber != 0 {
 		s.WriteString(", fuzzy: " + logs.Yellow(strconv.Itoa(stat.FuzzyNumber)))
@@ -74,11 +172,38 @@ func (stat *Statistor) ColorString() string {
 	if stat.WafedNumber != 0 {
 		s.WriteString(", wafed: " + logs.Yellow(strconv.Itoa(stat.WafedNumber)))
 	}
+	if stat.Waf != "" {
+		s.WriteString(", waf: " + logs.Yellow(stat.Waf))
+	}
+	if stat.Rebaselines != 0 {
+		s.WriteString(", rebaseline: " + logs.Yellow(strconv.Itoa(stat.Rebaselines)))
+	}
+	if stat.Stopped != "" {
+		s.WriteString(", stopped by " + logs.Red(stat.Stopped))
+	}
+	if len(stat.AutoExtensions) > 0 {
+		s.WriteString(", auto-ext: " + logs.Cyan(strings.Join(stat.AutoExtensions, ",")))
+	}
+	for class, count := range stat.Errors {
+		s.WriteString(", " + class + ": " + logs.Yellow(strconv.Itoa(count)))
+	}
+	for kind, count := range stat.BakGenerated {
+		s.WriteString(fmt.Sprintf(", bak.%s: %s/%s", kind, logs.Yellow(strconv.Itoa(stat.BakFound[kind])), logs.Yellow(strconv.Itoa(count))))
+	}
+	for class, count := range stat.APIClasses {
+		s.WriteString(fmt.Sprintf(", api.%s: %s", class, logs.Yellow(strconv.Itoa(count))))
+	}
+	if stat.MirrorOf != "" {
+		s.WriteString(", mirror of: " + logs.Yellow(stat.MirrorOf))
+	}
+	if len(stat.Bans) != 0 {
+		s.WriteString(fmt.Sprintf(", banned: %s times %s s", logs.Yellow(strconv.Itoa(len(stat.Bans))), logs.Yellow(strconv.Itoa(int(stat.BanTime())))))
+	}
 	return s.String()
 }
 func (stat *Statistor) String() string {
 	var s strings.Builder
-	s.WriteString(fmt.Sprintf("[stat] %s took %d s, request total: %d, finish: %d/%d, found: %d, check: %d, failed: %d", stat.BaseUrl, stat.EndTime-stat.StartTime, stat.ReqTotal, stat.End, stat.Total, stat.FoundNumber, stat.CheckNumber, stat.FailedNumber))
+	s.WriteString(fmt.Sprintf("[stat] %s took %d s, request total: %d, finish: %d/%d, found: %d, check: %d, failed: %d", stat.BaseUrl, stat.EndTime-stat.StartTime, stat.ReqTotal, stat.CompletedNumber(), stat.Total, stat.FoundNumber, stat.CheckNumber, stat.FailedNumber))
 
 	if stat.FuzzyNumber != 0 {
 		s.WriteString(", fuzzy: " + strconv.Itoa(stat.FuzzyNumber))
@@ -89,6 +214,33 @@ func (stat *Statistor) String() string {
 	if stat.WafedNumber != 0 {
 		s.WriteString(", wafed: " + strconv.Itoa(stat.WafedNumber))
 	}
+	if stat.Waf != "" {
+		s.WriteString(", waf: " + stat.Waf)
+	}
+	if stat.Rebaselines != 0 {
+		s.WriteString(", rebaseline: " + strconv.Itoa(stat.Rebaselines))
+	}
+	if stat.Stopped != "" {
+		s.WriteString(", stopped by " + stat.Stopped)
+	}
+	if len(stat.AutoExtensions) > 0 {
+		s.WriteString(", auto-ext: " + strings.Join(stat.AutoExtensions, ","))
+	}
+	for class, count := range stat.Errors {
+		s.WriteString(", " + class + ": " + strconv.Itoa(count))
+	}
+	for kind, count := range stat.BakGenerated {
+		s.WriteString(fmt.Sprintf(", bak.%s: %d/%d", kind, stat.BakFound[kind], count))
+	}
+	for class, count := range stat.APIClasses {
+		s.WriteString(fmt.Sprintf(", api.%s: %d", class, count))
+	}
+	if stat.MirrorOf != "" {
+		s.WriteString(", mirror of: " + stat.MirrorOf)
+	}
+	if len(stat.Bans) != 0 {
+		s.WriteString(fmt.Sprintf(", banned: %d times %d s", len(stat.Bans), stat.BanTime()))
+	}
 	return s.String()
 }
 
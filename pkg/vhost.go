@@ -0,0 +1,92 @@
+package pkg
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Vhost host模式下发现的虚拟主机, 记录ip与域名的对应关系, 以及title, 指纹与证书等佐证信息
+type Vhost struct {
+	Host       string   `json:"host"`
+	Status     int      `json:"status"`
+	Length     int      `json:"length"`
+	Title      string   `json:"title,omitempty"`
+	Frameworks []string `json:"frameworks,omitempty"`
+	Cert       string   `json:"cert,omitempty"`
+}
+
+func NewVhost(bl *Baseline) *Vhost {
+	v := &Vhost{
+		Status: bl.Status,
+		Length: bl.BodyLength,
+		Title:  bl.Title,
+		Cert:   bl.Cert,
+	}
+	if bl.Url != nil {
+		v.Host = bl.Url.Host
+	}
+	for name := range bl.Frameworks {
+		v.Frameworks = append(v.Frameworks, name)
+	}
+	sort.Strings(v.Frameworks)
+	return v
+}
+
+func (v *Vhost) String() string {
+	var s strings.Builder
+	s.WriteString(fmt.Sprintf("%s [%d] length: %d", v.Host, v.Status, v.Length))
+	if v.Title != "" {
+		s.WriteString(", title: " + v.Title)
+	}
+	if len(v.Frameworks) > 0 {
+		s.WriteString(", frameworks: " + strings.Join(v.Frameworks, ","))
+	}
+	if v.Cert != "" {
+		s.WriteString(", cert: " + v.Cert)
+	}
+	return s.String()
+}
+
+// ParentDomain 上级域名, 例如admin.example.com返回example.com. 二级域名返回自身, ip返回空
+func ParentDomain(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "" || net.ParseIP(host) != nil {
+		return ""
+	}
+	labels := strings.Split(strings.TrimSuffix(host, "."), ".")
+	if len(labels) <= 2 {
+		return strings.Join(labels, ".")
+	}
+	return strings.Join(labels[1:], ".")
+}
+
+// RandSubdomain 生成指定域名下的随机子域名, 用于校准同一个上级域名下的vhost
+func RandSubdomain(parent string) string {
+	return strings.ToLower(RandPath()) + "." + parent
+}
+
+// CertSummary 证书的CN与SAN, 并标记证书是否覆盖了该域名
+func CertSummary(cert *x509.Certificate, host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	names := []string{cert.Subject.CommonName}
+	for _, name := range cert.DNSNames {
+		if name != cert.Subject.CommonName {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 4 {
+		names = append(names[:4], fmt.Sprintf("...(%d)", len(cert.DNSNames)))
+	}
+	summary := strings.Join(names, ",")
+	if cert.VerifyHostname(host) == nil {
+		summary += " (match)"
+	}
+	return summary
+}
@@ -0,0 +1,180 @@
+package pkg
+
+import (
+	"bytes"
+	"strings"
+)
+
+var (
+	// 拦截页面常见的状态码, 只有在这些状态码下, header与cookie特征才会被认为是拦截
+	BlockStatus = []int{403, 405, 406, 418, 429, 493, 501, 503, 1020}
+	WafPayload  = "?id=1%27%20union%20select%201,2,3,database()--+&file=../../../../etc/passwd&q=%3Cscript%3Ealert(1)%3C/script%3E"
+)
+
+// WafSignature waf厂商的特征. header与cookie特征说明目标部署了该waf, 但正常页面同样会携带;
+// body特征同样可能出现在正常页面中, 只在拦截状态码下才会被认为是拦截页面
+type WafSignature struct {
+	Name        string
+	Headers     []string // header中的关键字, 不区分大小写, 例如"server: cloudflare", "x-sucuri-id"
+	Cookies     []string // cookie名的前缀
+	Bodies      []string // 拦截页面中的关键字
+	BlockStatus []int    // 拦截页面的状态码, 为空时使用BlockStatus
+}
+
+var WafSignatures = []*WafSignature{
+	{
+		Name:    "cloudflare",
+		Headers: []string{"server: cloudflare", "cf-ray:", "cf-mitigated:"},
+		Cookies: []string{"__cf_bm", "cf_clearance", "__cfduid"},
+		Bodies:  []string{"Attention Required! | Cloudflare", "cf-error-details", "Sorry, you have been blocked"},
+	},
+	{
+		Name:    "akamai",
+		Headers: []string{"server: akamaighost", "akamai-grn:"},
+		Cookies: []string{"ak_bmsc", "bm_sv"},
+		Bodies:  []string{"Reference&#32;&#35;", "errors.edgesuite.net"},
+	},
+	{
+		Name:    "aws-waf",
+		Headers: []string{"x-amzn-waf-"},
+		Cookies: []string{"aws-waf-token"},
+		Bodies:  []string{"Request blocked. We can't connect to the server for this app or website at this time"},
+	},
+	{
+		Name:    "imperva",
+		Headers: []string{"x-iinfo:", "x-cdn: incapsula"},
+		Cookies: []string{"incap_ses_", "visid_incap_", "nlbi_"},
+		Bodies:  []string{"Incapsula incident ID", "_Incapsula_Resource", "Request unsuccessful. Incapsula"},
+	},
+	{
+		Name:    "f5-bigip-asm",
+		Headers: []string{"x-wa-info:", "server: bigip"},
+		Cookies: []string{"TS01", "BIGipServer", "F5_fullWT"},
+		Bodies:  []string{"The requested URL was rejected. Please consult with your administrator."},
+		// asm默认的拦截页面状态码为200
+		BlockStatus: []int{200, 403},
+	},
+	{
+		Name:    "modsecurity",
+		Headers: []string{"server: mod_security", "mod_security"},
+		Bodies:  []string{"This error was generated by Mod_Security", "ModSecurity Action"},
+	},
+	{
+		Name:    "sucuri",
+		Headers: []string{"x-sucuri-id:", "server: sucuri", "x-sucuri-block:"},
+		Cookies: []string{"sucuri_cloudproxy"},
+		Bodies:  []string{"Sucuri WebSite Firewall - Access Denied", "sucuri.net/privacy-policy"},
+	},
+	{
+		Name:    "fortiweb",
+		Cookies: []string{"FORTIWAFSID", "cookiesession1"},
+		Bodies:  []string{".fgd_icon", "FortiGuard Intrusion Prevention"},
+	},
+	{
+		Name:    "barracuda",
+		Cookies: []string{"barra_counter_session", "BNI__BARRACUDA_LB_COOKIE"},
+		Bodies:  []string{"You have been blocked by Barracuda", "barracuda.com/support"},
+	},
+	{
+		Name:    "safeline",
+		Headers: []string{"server: safeline", "x-safeline-"},
+		Cookies: []string{"sl-session"},
+		Bodies:  []string{"/.safeline/", "safeline_bot_challenge", "雷池"},
+	},
+	{
+		Name:    "safedog",
+		Headers: []string{"x-powered-by: waf/2.0", "server: safedog"},
+		Cookies: []string{"safedog-flow-item"},
+		Bodies:  []string{"safedog.cn", "安全狗"},
+	},
+	{
+		Name:    "aliyun-waf",
+		Headers: []string{"server: tengine/aserver"},
+		Cookies: []string{"aliyungf_tc", "acw_tc", "acw_sc__"},
+		Bodies:  []string{"/waf/banned", "阿里云盾"},
+	},
+	{
+		Name:    "tencent-waf",
+		Cookies: []string{"TCWAF"},
+		Bodies:  []string{"waf.tencent-cloud.com", "腾讯T-Sec Web应用防火墙"},
+	},
+	{
+		Name:   "baota-waf",
+		Bodies: []string{"宝塔网站防火墙", "您的请求带有不合法参数，已被网站管理员设置拦截"},
+	},
+	{
+		Name:    "360wzb",
+		Headers: []string{"x-powered-by-360wzb", "wzws-ray:"},
+		Cookies: []string{"wzws_cid", "wzws_reurl"},
+		Bodies:  []string{"wangzhan.360.cn", "360网站卫士"},
+	},
+	{
+		Name:    "yundun",
+		Headers: []string{"server: yundun", "x-cache: yundun"},
+		Cookies: []string{"yd_cookie"},
+		Bodies:  []string{"yundun.com/waf", "YUNDUN WAF"},
+	},
+	{
+		Name:    "chuangyu",
+		Headers: []string{"x-powered-by: anquanbao", "server: jiasule"},
+		Cookies: []string{"__jsluid", "jsl_tracking"},
+		Bodies:  []string{"365cyd.com", "创宇盾", "notice.jiasule.com"},
+	},
+	{
+		Name:    "huaweicloud-waf",
+		Cookies: []string{"HWWAFSESID", "HWWAFSESTIME"},
+		Bodies:  []string{"华为云Web应用防火墙"},
+	},
+}
+
+// WafVendor 根据header与cookie特征识别部署的waf厂商
+func WafVendor(header []byte) string {
+	lower := bytes.ToLower(header)
+	for _, sign := range WafSignatures {
+		for _, h := range sign.Headers {
+			if bytes.Contains(lower, []byte(strings.ToLower(h))) {
+				return sign.Name
+			}
+		}
+		for _, line := range bytes.Split(header, []byte("\n")) {
+			if !bytes.HasPrefix(bytes.ToLower(line), []byte("set-cookie:")) {
+				continue
+			}
+			cookie := bytes.TrimSpace(line[len("set-cookie:"):])
+			for _, c := range sign.Cookies {
+				if bytes.HasPrefix(cookie, []byte(c)) {
+					return sign.Name
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// BlockPage 根据拦截页面的特征识别waf厂商, 状态码不是该厂商的拦截状态码时不进行匹配
+func BlockPage(status int, body []byte) string {
+	for _, sign := range WafSignatures {
+		if !sign.blocked(status) {
+			continue
+		}
+		for _, b := range sign.Bodies {
+			if bytes.Contains(body, []byte(b)) {
+				return sign.Name
+			}
+		}
+	}
+	return ""
+}
+
+func (sign *WafSignature) blocked(status int) bool {
+	statuses := sign.BlockStatus
+	if statuses == nil {
+		statuses = BlockStatus
+	}
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
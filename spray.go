@@ -1,4 +1,4 @@
-//go:generate go run templates/templates_gen.go -t templates -o pkg/templates.go -need http,rule,mask,extract
+//go:generate go run templates/templates_gen.go -t templates -o pkg/templates.go -need http,rule,mask,extract,pack
 package main
 
 import "github.com/chainreactors/spray/cmd"